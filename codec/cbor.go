@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR is a codec for the CBOR format (RFC 7049)
+var CBOR Codec = cborCodec{}
+
+var (
+	cborEncMode cbor.EncMode
+	cborDecMode cbor.DecMode
+)
+
+func init() {
+	var err error
+
+	// canonical encoding keeps the files stable, so that git diffs only show real changes
+	cborEncMode, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("codec: invalid CBOR encoding options: " + err.Error())
+	}
+
+	// maps are decoded as map[string]interface{} like in the other codecs
+	cborDecMode, err = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic("codec: invalid CBOR decoding options: " + err.Error())
+	}
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string { return "cbor" }
+
+func (cborCodec) Encode(w io.Writer, v interface{}) error {
+	return cborEncMode.NewEncoder(w).Encode(v)
+}
+
+func (cborCodec) Decode(r io.Reader, v interface{}) error {
+	return cborDecMode.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec serializes the content of node and edge files
+type Codec interface {
+	// Name identifies the codec inside the files it has written, it must not contain a newline
+	Name() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	codecsMx sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	Register(JSON)
+	Register(MsgPack)
+	Register(CBOR)
+}
+
+// Register registers a codec, so that files written by it can be read.
+// JSON, MsgPack and CBOR are registered by default.
+func Register(c Codec) {
+	codecsMx.Lock()
+	codecs[c.Name()] = c
+	codecsMx.Unlock()
+}
+
+// Lookup returns the registered codec with the given name
+func Lookup(name string) (c Codec, has bool) {
+	codecsMx.RLock()
+	c, has = codecs[name]
+	codecsMx.RUnlock()
+	return
+}
+
+/*
+every file starts with a header line that consists of a # followed by the name of
+the codec that wrote the file, e.g.
+
+	#msgpack
+	[msgpack encoded data]
+
+files without header have been written by older versions and are JSON.
+*/
+
+const headerMark = '#'
+
+// Write writes the header of c and the data encoded by c to w
+func Write(w io.Writer, c Codec, data interface{}) error {
+	if _, err := fmt.Fprintf(w, "%c%s\n", headerMark, c.Name()); err != nil {
+		return err
+	}
+	return c.Encode(w, data)
+}
+
+// Read decodes the data of r to v, using the codec that is named inside the header of r.
+// It returns the codec that has been used.
+func Read(r io.Reader, v interface{}) (Codec, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] != headerMark {
+		return JSON, JSON.Decode(br, v)
+	}
+
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("invalid codec header: %s", err)
+	}
+
+	name := string(bytes.TrimSpace(line[1:]))
+	c, has := Lookup(name)
+	if !has {
+		return nil, fmt.Errorf("unknown codec %#v", name)
+	}
+	return c, c.Decode(br, v)
+}
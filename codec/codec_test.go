@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecs(t *testing.T) {
+	born := time.Date(1971, 3, 9, 0, 0, 0, 0, time.UTC)
+	props := map[string]interface{}{
+		"Age":       int64(44),
+		"Height":    float64(1.82),
+		"FirstName": "Donald",
+		"Married":   true,
+		"Tags":      []string{"duck", "famous"},
+		"Ints":      []int64{1, 2},
+		"Born":      born,
+	}
+
+	for _, c := range []Codec{JSON, MsgPack, CBOR} {
+		enc, err := EncodeProperties(props)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := Write(&buf, c, enc); err != nil {
+			t.Errorf("%s: Write() returned error: %s", c.Name(), err)
+			continue
+		}
+
+		if !strings.HasPrefix(buf.String(), "#"+c.Name()+"\n") {
+			t.Errorf("%s: missing header", c.Name())
+		}
+
+		data := map[string]interface{}{}
+		used, err := Read(&buf, &data)
+		if err != nil {
+			t.Errorf("%s: Read() returned error: %s", c.Name(), err)
+			continue
+		}
+
+		if used.Name() != c.Name() {
+			t.Errorf("%s: Read() used codec %s", c.Name(), used.Name())
+		}
+
+		got, err := DecodeProperties(data)
+		if err != nil {
+			t.Errorf("%s: DecodeProperties() returned error: %s", c.Name(), err)
+			continue
+		}
+
+		if tm, ok := got["Born"].(time.Time); !ok || !tm.Equal(born) {
+			t.Errorf("%s: Born = %#v, expected %#v", c.Name(), got["Born"], born)
+		}
+		got["Born"] = born
+
+		if !reflect.DeepEqual(got, props) {
+			t.Errorf("%s: got %#v, expected %#v", c.Name(), got, props)
+		}
+	}
+}
+
+func TestReadWithoutHeader(t *testing.T) {
+	data := map[string]string{}
+	c, err := Read(strings.NewReader(`{"shard1-abc":"def"}`+"\n"), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c != JSON {
+		t.Errorf("Read() used codec %s, expected json", c.Name())
+	}
+
+	if expected := map[string]string{"shard1-abc": "def"}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("Read() = %#v, expected %#v", data, expected)
+	}
+}
+
+func TestReadUnknownCodec(t *testing.T) {
+	var data interface{}
+	if _, err := Read(strings.NewReader("#unknown\n{}"), &data); err == nil {
+		t.Errorf("expected error for unknown codec")
+	}
+}
@@ -0,0 +1,344 @@
+// Package codec handles the encoding of node properties.
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+/*
+properties are saved with their type, so that they come back as they were set.
+each value is wrapped inside a map with a single key that is the name of the type, e.g.
+
+	{"Age": {"int": "44"}, "Born": {"time": "1971-03-09T00:00:00Z"}, "Tags": {"[]string": ["a", "b"]}}
+
+ints are saved as decimal strings, so that they are not subject to the precision of floats
+in the serialization format, times are saved as RFC3339 strings with nanoseconds.
+
+values that are not wrapped (as written by older versions) are returned as they were
+decoded by the serialization format (e.g. float64 for any JSON number).
+*/
+
+const (
+	TypeInt     = "int"
+	TypeFloat   = "float"
+	TypeBool    = "bool"
+	TypeString  = "string"
+	TypeTime    = "time"
+	TypeInts    = "[]int"
+	TypeFloats  = "[]float"
+	TypeBools   = "[]bool"
+	TypeStrings = "[]string"
+	TypeTimes   = "[]time"
+)
+
+// EncodeProperties wraps each property value with its type, see EncodeValue
+func EncodeProperties(props map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		enc, err := EncodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("can't encode property %#v: %s", k, err)
+		}
+		res[k] = enc
+	}
+	return res, nil
+}
+
+// DecodeProperties unwraps the property values that have been wrapped by EncodeProperties.
+// Values that are not wrapped are returned unchanged.
+func DecodeProperties(data map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		dec, err := DecodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("can't decode property %#v: %s", k, err)
+		}
+		res[k] = dec
+	}
+	return res, nil
+}
+
+func wrap(typ string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{typ: val}
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// EncodeValue wraps a value of the supported types
+// int64, float64, bool, string, time.Time and their slices with its type.
+// A *time.Time is handled like a time.Time, a nil *time.Time like nil.
+// Other integer and float types are converted to int64 and float64, unsigned integers
+// that don't fit into an int64 are an error.
+// Values of other types are not supported and return an error, since they would not be
+// decoded as the same type.
+func EncodeValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		return wrap(TypeInt, strconv.FormatInt(x, 10)), nil
+	case int:
+		return EncodeValue(int64(x))
+	case int32:
+		return EncodeValue(int64(x))
+	case int16:
+		return EncodeValue(int64(x))
+	case int8:
+		return EncodeValue(int64(x))
+	case uint:
+		return encodeUint(uint64(x))
+	case uint64:
+		return encodeUint(x)
+	case uint32:
+		return EncodeValue(int64(x))
+	case uint16:
+		return EncodeValue(int64(x))
+	case uint8:
+		return EncodeValue(int64(x))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("unsupported float value %v", x)
+		}
+		return wrap(TypeFloat, x), nil
+	case float32:
+		return EncodeValue(float64(x))
+	case bool:
+		return wrap(TypeBool, x), nil
+	case string:
+		return wrap(TypeString, x), nil
+	case time.Time:
+		return wrap(TypeTime, formatTime(x)), nil
+	case *time.Time:
+		if x == nil {
+			return nil, nil
+		}
+		return EncodeValue(*x)
+	case []int64:
+		vals := make([]interface{}, len(x))
+		for i, n := range x {
+			vals[i] = strconv.FormatInt(n, 10)
+		}
+		return wrap(TypeInts, vals), nil
+	case []float64:
+		vals := make([]interface{}, len(x))
+		for i, f := range x {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, fmt.Errorf("unsupported float value %v", f)
+			}
+			vals[i] = f
+		}
+		return wrap(TypeFloats, vals), nil
+	case []bool:
+		vals := make([]interface{}, len(x))
+		for i, b := range x {
+			vals[i] = b
+		}
+		return wrap(TypeBools, vals), nil
+	case []string:
+		vals := make([]interface{}, len(x))
+		for i, s := range x {
+			vals[i] = s
+		}
+		return wrap(TypeStrings, vals), nil
+	case []time.Time:
+		vals := make([]interface{}, len(x))
+		for i, t := range x {
+			vals[i] = formatTime(t)
+		}
+		return wrap(TypeTimes, vals), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func encodeUint(x uint64) (interface{}, error) {
+	if x > math.MaxInt64 {
+		return nil, fmt.Errorf("unsupported int value %d: overflows int64", x)
+	}
+	return EncodeValue(int64(x))
+}
+
+// DecodeValue unwraps a value that has been wrapped by EncodeValue and
+// returns it in its original type. Values that are not wrapped are returned unchanged.
+func DecodeValue(v interface{}) (interface{}, error) {
+	typ, val, ok := unwrap(v)
+	if !ok {
+		return v, nil
+	}
+
+	switch typ {
+	case TypeInt:
+		return toInt(val)
+	case TypeFloat:
+		return toFloat(val)
+	case TypeBool:
+		b, ok := val.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %#v", val)
+		}
+		return b, nil
+	case TypeString:
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %#v", val)
+		}
+		return s, nil
+	case TypeTime:
+		return toTime(val)
+	}
+
+	vals, ok := val.([]interface{})
+	if !ok && val != nil {
+		return nil, fmt.Errorf("invalid %s %#v", typ, val)
+	}
+
+	switch typ {
+	case TypeInts:
+		res := make([]int64, len(vals))
+		for i, x := range vals {
+			n, err := toInt(x)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = n
+		}
+		return res, nil
+	case TypeFloats:
+		res := make([]float64, len(vals))
+		for i, x := range vals {
+			f, err := toFloat(x)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = f
+		}
+		return res, nil
+	case TypeBools:
+		res := make([]bool, len(vals))
+		for i, x := range vals {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid bool %#v", x)
+			}
+			res[i] = b
+		}
+		return res, nil
+	case TypeStrings:
+		res := make([]string, len(vals))
+		for i, x := range vals {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid string %#v", x)
+			}
+			res[i] = s
+		}
+		return res, nil
+	default: // TypeTimes
+		res := make([]time.Time, len(vals))
+		for i, x := range vals {
+			t, err := toTime(x)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = t
+		}
+		return res, nil
+	}
+}
+
+// unwrap returns the type and the value of a wrapped value.
+// ok is false, if v is not a wrapped value.
+func unwrap(v interface{}) (typ string, val interface{}, ok bool) {
+	m, isMap := v.(map[string]interface{})
+	if !isMap {
+		// some serialization formats decode maps as map[interface{}]interface{}
+		mi, isMapI := v.(map[interface{}]interface{})
+		if !isMapI || len(mi) != 1 {
+			return "", nil, false
+		}
+		for k, vv := range mi {
+			ks, isString := k.(string)
+			if !isString {
+				return "", nil, false
+			}
+			m = map[string]interface{}{ks: vv}
+		}
+	}
+
+	if len(m) != 1 {
+		return "", nil, false
+	}
+
+	for k, vv := range m {
+		typ, val = k, vv
+	}
+
+	switch typ {
+	case TypeInt, TypeFloat, TypeBool, TypeString, TypeTime,
+		TypeInts, TypeFloats, TypeBools, TypeStrings, TypeTimes:
+		return typ, val, true
+	}
+	return "", nil, false
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case string:
+		return strconv.ParseInt(x, 10, 64)
+	case json.Number:
+		return x.Int64()
+	case float64:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case uint64:
+		return int64(x), nil
+	case int:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case uint8:
+		return int64(x), nil
+	case uint16:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	}
+	return 0, fmt.Errorf("invalid int %#v", v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case json.Number:
+		return x.Float64()
+	case string:
+		return strconv.ParseFloat(x, 64)
+	}
+	n, err := toInt(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float %#v", v)
+	}
+	return float64(n), nil
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time %#v", v)
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
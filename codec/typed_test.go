@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func roundTrip(props map[string]interface{}) (map[string]interface{}, error) {
+	enc, err := EncodeProperties(props)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return DecodeProperties(data)
+}
+
+func TestRoundTrip(t *testing.T) {
+	born := time.Date(1971, 3, 9, 12, 30, 15, 123456789, time.UTC)
+
+	tests := []map[string]interface{}{
+		{"Age": int64(44)},
+		{"Big": int64(9007199254740993)},
+		{"Height": float64(1.82)},
+		{"Married": true},
+		{"FirstName": "Donald"},
+		{"Born": born},
+		{"Ints": []int64{1, -2, 9007199254740993}},
+		{"Floats": []float64{1.5, -2.25}},
+		{"Bools": []bool{true, false}},
+		{"Strings": []string{"a", "b"}},
+		{"Times": []time.Time{born, born.Add(time.Hour)}},
+		{"Empty": []string{}},
+		{"Nil": nil},
+	}
+
+	for i, test := range tests {
+		got, err := roundTrip(test)
+		if err != nil {
+			t.Errorf("[%d] roundTrip(%#v) returned error: %s", i, test, err)
+			continue
+		}
+
+		for k, v := range test {
+			// time.Time must be compared with Equal because of the location
+			if tm, ok := v.(time.Time); ok {
+				if gotTm, ok := got[k].(time.Time); !ok || !gotTm.Equal(tm) {
+					t.Errorf("[%d] roundTrip()[%#v] = %#v, expected %#v", i, k, got[k], v)
+				}
+				continue
+			}
+
+			if tms, ok := v.([]time.Time); ok {
+				gotTms, ok := got[k].([]time.Time)
+				if !ok || len(gotTms) != len(tms) {
+					t.Errorf("[%d] roundTrip()[%#v] = %#v, expected %#v", i, k, got[k], v)
+					continue
+				}
+				for j := range tms {
+					if !gotTms[j].Equal(tms[j]) {
+						t.Errorf("[%d] roundTrip()[%#v][%d] = %v, expected %v", i, k, j, gotTms[j], tms[j])
+					}
+				}
+				continue
+			}
+
+			if !reflect.DeepEqual(got[k], v) {
+				t.Errorf("[%d] roundTrip()[%#v] = %#v, expected %#v", i, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestTimePointer(t *testing.T) {
+	born := time.Date(1971, 3, 9, 0, 0, 0, 0, time.UTC)
+	var nilTime *time.Time
+
+	got, err := roundTrip(map[string]interface{}{"Born": &born, "Died": nilTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tm, ok := got["Born"].(time.Time); !ok || !tm.Equal(born) {
+		t.Errorf("Born = %#v, expected %#v", got["Born"], born)
+	}
+
+	if got["Died"] != nil {
+		t.Errorf("Died = %#v, expected nil", got["Died"])
+	}
+}
+
+func TestUnsupportedValues(t *testing.T) {
+	tests := []interface{}{
+		uint64(1 << 63),
+		[]interface{}{"a", 1},
+		struct{ Name string }{"Donald"},
+		map[string]interface{}{"a": "b"},
+		[]int{1, 2},
+	}
+
+	for _, test := range tests {
+		if _, err := EncodeValue(test); err == nil {
+			t.Errorf("EncodeValue(%#v) should return an error", test)
+		}
+	}
+
+	got, err := roundTrip(map[string]interface{}{"Count": uint64(42), "Small": uint8(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]interface{}{"Count": int64(42), "Small": int64(7)}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("roundTrip() = %#v, expected %#v", got, expected)
+	}
+}
+
+func TestLegacyValues(t *testing.T) {
+	data := map[string]interface{}{}
+	err := json.Unmarshal([]byte(`{"Age":44,"FirstName":"Donald","Born":"1971-03-09T00:00:00Z","Tags":["a"]}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeProperties(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"Age":       float64(44),
+		"FirstName": "Donald",
+		"Born":      "1971-03-09T00:00:00Z",
+		"Tags":      []interface{}{"a"},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("DecodeProperties() = %#v, expected %#v", got, expected)
+	}
+}
@@ -1,6 +1,10 @@
 package zoom
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type CommitMessage struct {
 	Host    string
@@ -31,3 +35,69 @@ func (c CommitMessage) String() string {
 		c.Details,
 	)
 }
+
+// the trailers that are appended to the commit message by Format, one per field except Details
+var commitTrailers = []struct {
+	key   string
+	field func(*CommitMessage) *string
+}{
+	{"Zoom-Host", func(c *CommitMessage) *string { return &c.Host }},
+	{"Zoom-User", func(c *CommitMessage) *string { return &c.User }},
+	{"Zoom-App", func(c *CommitMessage) *string { return &c.App }},
+	{"Zoom-Version", func(c *CommitMessage) *string { return &c.Version }},
+	{"Zoom-Command", func(c *CommitMessage) *string { return &c.Command }},
+}
+
+// quoteTrailer quotes values that would not survive as trailer value
+func quoteTrailer(val string) string {
+	if strings.ContainsAny(val, "\r\n") || strings.HasPrefix(val, `"`) || strings.TrimSpace(val) != val {
+		return strconv.Quote(val)
+	}
+	return val
+}
+
+// Format returns the commit message as written by the stores: String() followed by an empty line
+// and a git trailer for each field except Details, so that it can be parsed back by ParseCommitMessage
+func (c CommitMessage) Format() string {
+	var b strings.Builder
+	b.WriteString(c.String())
+	b.WriteString("\n")
+	for _, t := range commitTrailers {
+		fmt.Fprintf(&b, "%s: %s\n", t.key, quoteTrailer(*t.field(&c)))
+	}
+	return b.String()
+}
+
+// ParseCommitMessage parses a commit message that has been written by Format.
+// If the text has no trailers (e.g. written by older versions), ok is false and
+// the whole text is returned as Details.
+func ParseCommitMessage(text string) (c CommitMessage, ok bool) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+
+	start := len(lines)
+	values := map[string]string{}
+	for start > 0 {
+		line := lines[start-1]
+		idx := strings.Index(line, ":")
+		if idx == -1 || !strings.HasPrefix(line, "Zoom-") {
+			break
+		}
+		key, val := line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		if unquoted, err := strconv.Unquote(val); err == nil && strings.HasPrefix(val, `"`) {
+			val = unquoted
+		}
+		values[key] = val
+		start--
+	}
+
+	// the trailers are separated by an empty line from the lines written by String()
+	if start == len(lines) || start < 3 || lines[start-1] != "" {
+		return CommitMessage{Details: text}, false
+	}
+
+	for _, t := range commitTrailers {
+		*t.field(&c) = values[t.key]
+	}
+	c.Details = strings.Join(lines[2:start-1], "\n")
+	return c, true
+}
@@ -0,0 +1,34 @@
+package zoom_test
+
+import (
+	"testing"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []zoom.CommitMessage{
+		{Host: "host1", User: "donald", App: "shop", Version: "1.2", Command: "buy", Details: "bought\n2 things"},
+		{App: "shop", Command: "buy"},
+		{User: "\"quoted\"", Command: " spaced ", Details: "Zoom-User: fake"},
+		{User: "multi\nline"},
+		{},
+	}
+
+	for _, test := range tests {
+		msg, ok := zoom.ParseCommitMessage(test.Format())
+		if !ok {
+			t.Errorf("ParseCommitMessage(%#v) could not parse", test.Format())
+			continue
+		}
+		if msg != test {
+			t.Errorf("ParseCommitMessage(%#v) = %#v, expected %#v", test.Format(), msg, test)
+		}
+	}
+
+	legacy := zoom.CommitMessage{App: "shop", Command: "buy"}.String()
+	msg, ok := zoom.ParseCommitMessage(legacy)
+	if ok || msg.Details != legacy {
+		t.Errorf("ParseCommitMessage(%#v) = %#v, %v, expected the text as Details", legacy, msg, ok)
+	}
+}
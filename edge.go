@@ -1,10 +1,44 @@
 package zoom
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+	the value of an edge inside the edges file is the uuid of the property node (empty if the edge has none),
+	optionally followed by a semicolon and the weight of the edge and another semicolon and the position
+	of the edge, e.g.
+
+		"7196aced-8418-4412-b0ce-4994998aa73f;2.5", ";2.5" or ";0;3"
+
+	edges with a weight of 0 are saved without weight and edges without position (0) are saved without
+	position, so edges files without weights and positions (as written by older versions) are still valid.
+
+	the key of an edge inside the edges file is the id of the target node ("shard-uuid"). there may be
+	several edges of the same category between two nodes, then each additional edge has an own id
+	that is appended to the key after a #, e.g.
+
+		"shard1-7196aced-8418-4412-b0ce-4994998aa73f#0b1ca0a6-5c1d-4e47-9b6e-2d4b0c0bb1f1"
+
+	the edge without id (as written by older versions or NewEdge) is the default edge between two nodes.
+	the keys of the incoming edges are built the same way with the id of the source node.
+*/
+
 type Edge struct {
+	// ID is empty for the default edge between two nodes and distinguishes parallel edges (see Node.AddEdge)
+	ID         string
 	Category   string
 	From       *Node
 	To         *Node
 	Properties *Node
+	Weight     float64
+
+	// Position is the 1-based position of the edge in the order of the edges of the category
+	// or 0 if the edge is not ordered (see ordered.go)
+	Position int
 }
 
 func NewEdge(category string, from, to, properties *Node) *Edge {
@@ -16,17 +50,174 @@ func NewEdge(category string, from, to, properties *Node) *Edge {
 	}
 }
 
+// EdgeKey returns the key of an edge inside the edges file for the given node id ("shard-uuid") and edge id
+func EdgeKey(nodeID, edgeID string) string {
+	if edgeID == "" {
+		return nodeID
+	}
+	return nodeID + "#" + edgeID
+}
+
+// SplitEdgeKey returns the shard and uuid of the node and the edge id of a key inside an edges file
+func SplitEdgeKey(key string) (shard, uuid, edgeID string, err error) {
+	if idx := strings.Index(key, "#"); idx != -1 {
+		key, edgeID = key[:idx], key[idx+1:]
+	}
+	shard, uuid, err = SplitID(key)
+	return
+}
+
+// FormatEdgeValue returns the value of an edge inside the edges file
+func FormatEdgeValue(propID string, weight float64, position int) string {
+	if weight == 0 && position == 0 {
+		return propID
+	}
+	val := propID + ";" + strconv.FormatFloat(weight, 'g', -1, 64)
+	if position == 0 {
+		return val
+	}
+	return val + ";" + strconv.Itoa(position)
+}
+
+// ParseEdgeValue returns the uuid of the property node (may be empty), the weight and the position
+// of an edge value inside the edges file
+func ParseEdgeValue(val string) (propID string, weight float64, position int, err error) {
+	parts := strings.Split(val, ";")
+	if len(parts) > 3 {
+		return "", 0, 0, fmt.Errorf("invalid edge value %#v", val)
+	}
+	if len(parts) > 1 {
+		weight, err = strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("invalid weight in edge value %#v", val)
+		}
+	}
+	if len(parts) > 2 {
+		position, err = strconv.Atoi(parts[2])
+		if err != nil || position < 0 {
+			return "", 0, 0, fmt.Errorf("invalid position in edge value %#v", val)
+		}
+	}
+	return parts[0], weight, position, nil
+}
+
+// edgePropertyNodes returns the uuids of the property nodes of the edges of the given category
+// that the store removes together with the edges
+func edgePropertyNodes(st Store, category, uuid string) ([]string, error) {
+	edges, err := st.GetEdges(category, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var propIDs []string
+	for _, val := range edges {
+		propID, _, _, err := ParseEdgeValue(val)
+		if err != nil {
+			return nil, err
+		}
+		if propID != "" {
+			propIDs = append(propIDs, propID)
+		}
+	}
+	return propIDs, nil
+}
+
+// value returns the value of the edge inside the edges file
+func (e *Edge) value() (string, error) {
+	if math.IsNaN(e.Weight) || math.IsInf(e.Weight, 0) {
+		return "", fmt.Errorf("invalid weight %v for edge %s", e.Weight, e.Category)
+	}
+	if e.Position < 0 {
+		return "", fmt.Errorf("invalid position %v for edge %s", e.Position, e.Category)
+	}
+	if e.Properties != nil {
+		return FormatEdgeValue(e.Properties.Id, e.Weight, e.Position), nil
+	}
+	return FormatEdgeValue("", e.Weight, e.Position), nil
+}
+
+// newEdgeFromValue creates the edge for the given value of the edges file.
+// the property node belongs to the transaction of the from node
+func newEdgeFromValue(category, id string, from, to *Node, val string) (*Edge, error) {
+	propID, weight, position, err := ParseEdgeValue(val)
+	if err != nil {
+		return nil, err
+	}
+	e := NewEdge(category, from, to, nil)
+	e.ID = id
+	e.Position = position
+	if propID != "" {
+		e.Properties = NewNode(from.Transaction, propID)
+	}
+	e.Weight = weight
+	return e, nil
+}
+
+// key returns the key of the edge inside the edges file of the from node
+func (e *Edge) key() string {
+	return EdgeKey(e.To.Transaction.Shard()+"-"+e.To.Id, e.ID)
+}
+
+// incomingKey returns the key of the edge inside the incoming edges file of the to node
+func (e *Edge) incomingKey() string {
+	return EdgeKey(e.From.Transaction.Shard()+"-"+e.From.Id, e.ID)
+}
+
 func (e *Edge) Save() error {
+	val, err := e.value()
+	if err != nil {
+		return err
+	}
 	edges, err := e.From.Transaction.GetEdges(e.Category, e.From.Id)
 	if err != nil {
 		return err
 	}
-	if e.Properties != nil {
-		edges[e.To.Transaction.Shard()+"-"+e.To.Id] = e.Properties.Id
-	} else {
-		edges[e.To.Transaction.Shard()+"-"+e.To.Id] = ""
+	// keep the position of an existing edge
+	if old, has := edges[e.key()]; has && e.Position == 0 {
+		_, _, pos, err := ParseEdgeValue(old)
+		if err != nil {
+			return err
+		}
+		e.Position = pos
+		if val, err = e.value(); err != nil {
+			return err
+		}
+	}
+	edges[e.key()] = val
+	if err := e.From.Transaction.SaveEdges(e.Category, e.From.Id, edges); err != nil {
+		return err
 	}
-	return e.From.Transaction.SaveEdges(e.Category, e.From.Id, edges)
+	return e.saveIncoming()
+}
+
+// saveIncoming adds the edge to the incoming edges of the target node. the position is left out,
+// since it is the position among the edges of the source node that changes when they are reordered
+func (e *Edge) saveIncoming() error {
+	in := *e
+	in.Position = 0
+	val, err := in.value()
+	if err != nil {
+		return err
+	}
+	incoming, err := e.To.Transaction.GetIncomingEdges(e.Category, e.To.Id)
+	if err != nil {
+		return err
+	}
+	incoming[e.incomingKey()] = val
+	return e.To.Transaction.SaveIncomingEdges(e.Category, e.To.Id, incoming)
+}
+
+// removeIncoming removes the edge from the incoming edges of the target node
+func (e *Edge) removeIncoming() error {
+	incoming, err := e.To.Transaction.GetIncomingEdges(e.Category, e.To.Id)
+	if err != nil {
+		return err
+	}
+	delete(incoming, e.incomingKey())
+	if len(incoming) == 0 {
+		return e.To.Transaction.RemoveIncomingEdges(e.Category, e.To.Id)
+	}
+	return e.To.Transaction.SaveIncomingEdges(e.Category, e.To.Id, incoming)
 }
 
 // Remove only removes the Edge entry inside the from node edges, but not the property node of the edges
@@ -35,9 +226,32 @@ func (e *Edge) Remove() error {
 	if err != nil {
 		return err
 	}
-	delete(edges, e.To.Transaction.Shard()+"-"+e.To.Id)
+	delete(edges, e.key())
+	if err := saveRemainingEdges(e.From.Transaction, e.Category, e.From.Id, edges); err != nil {
+		return err
+	}
+	return e.removeIncoming()
+}
+
+// saveRemainingEdges saves the edges that are left after removing some of them and removes the edges file,
+// if none are left. RemoveEdges removes the property nodes of the edges inside the file, so the remaining
+// edges are saved before, to keep the property nodes of the removed edges
+func saveRemainingEdges(tr Transaction, category, fromUUID string, edges map[string]string) error {
+	if err := tr.SaveEdges(category, fromUUID, edges); err != nil {
+		return err
+	}
 	if len(edges) == 0 {
-		return e.From.Transaction.RemoveEdges(e.Category, e.From.Id)
+		return tr.RemoveEdges(category, fromUUID)
+	}
+	return nil
+}
+
+// Delete removes the edge together with its property node
+func (e *Edge) Delete() error {
+	if e.Properties != nil {
+		if err := e.Properties.Remove(); err != nil {
+			return err
+		}
 	}
-	return e.From.Transaction.SaveEdges(e.Category, e.From.Id, edges)
+	return e.Remove()
 }
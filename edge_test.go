@@ -0,0 +1,339 @@
+package zoom_test
+
+import (
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func sourceIDs(edges []*zoom.Edge) map[string]bool {
+	ids := map[string]bool{}
+	for _, e := range edges {
+		ids[e.From.ID()] = true
+	}
+	return ids
+}
+
+func TestIncomingEdges(t *testing.T) {
+	groups := memstore.New("groups")
+	persons := memstore.New("persons")
+
+	group := zoom.NewNode(groups, "")
+	group.SetString("Name", "ducks")
+	if err := group.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	donald := zoom.NewNode(persons, "")
+	daisy := zoom.NewNode(persons, "")
+	for _, n := range []*zoom.Node{donald, daisy} {
+		n.SetString("Name", "duck")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := donald.NewEdge("member", group, map[string]interface{}{"Since": "1934"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := daisy.NewEdge("member", group, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := group.GetIncomingEdges(persons, "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := sourceIDs(members); len(ids) != 2 || !ids[donald.ID()] || !ids[daisy.ID()] {
+		t.Errorf("members = %v, expected %s and %s", ids, donald.ID(), daisy.ID())
+	}
+
+	for _, e := range members {
+		if e.From.ID() == donald.ID() && e.Properties == nil {
+			t.Errorf("missing property node of the edge of donald")
+		}
+	}
+
+	if other, err := group.GetIncomingEdges(groups, "member"); err != nil || len(other) != 0 {
+		t.Errorf("incoming edges from other shard = %v, %v, expected none", other, err)
+	}
+
+	if err := donald.RemoveEdge("member", group); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err = group.GetIncomingEdges(persons, "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := sourceIDs(members); len(ids) != 1 || !ids[daisy.ID()] {
+		t.Errorf("members after RemoveEdge = %v, expected %s", ids, daisy.ID())
+	}
+
+	// the edges of a removed source node are removed from the incoming edges of the targets in its shard
+	if err := daisy.NewEdge("knows", donald, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := daisy.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	known, err := donald.GetIncomingEdges(persons, "knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(known) != 0 {
+		t.Errorf("incoming edges after Remove = %v, expected none", sourceIDs(known))
+	}
+
+	if in, err := persons.GetIncomingEdges("knows", donald.ID()); err != nil || len(in) != 0 {
+		t.Errorf("incoming edges file after Remove = %v, %v, expected none", in, err)
+	}
+}
+
+func TestEdgeWeights(t *testing.T) {
+	store := memstore.New("shard1")
+
+	a := zoom.NewNode(store, "")
+	b := zoom.NewNode(store, "")
+	c := zoom.NewNode(store, "")
+
+	if err := a.NewWeightedEdge("road", b, 2.5, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.NewWeightedEdge("road", c, 4, map[string]interface{}{"Name": "highway"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := a.GetEdge("road", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Weight != 2.5 || e.Properties != nil {
+		t.Errorf("edge a -> b: weight %v, properties %v, expected 2.5 and no properties", e.Weight, e.Properties)
+	}
+
+	e, err = a.GetEdge("road", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Weight != 4 || e.Properties == nil {
+		t.Fatalf("edge a -> c: weight %v, properties %v, expected 4 and a property node", e.Weight, e.Properties)
+	}
+
+	if err := e.Properties.LoadProperties([]string{"Name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := e.Properties.GetString("Name"); got != "highway" {
+		t.Errorf("Name of edge a -> c = %#v, expected %#v", got, "highway")
+	}
+
+	// removing the edge removes the property node despite the weight
+	if err := a.RemoveEdge("road", c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetNodeProperties(e.Properties.ID(), []string{"Name"}); err == nil {
+		t.Errorf("property node of removed edge still exists")
+	}
+}
+
+func TestParseEdgeValue(t *testing.T) {
+	tests := []struct {
+		val      string
+		propID   string
+		weight   float64
+		position int
+	}{
+		{"", "", 0, 0},
+		{"7196aced-8418-4412-b0ce-4994998aa73f", "7196aced-8418-4412-b0ce-4994998aa73f", 0, 0},
+		{";2.5", "", 2.5, 0},
+		{"7196aced-8418-4412-b0ce-4994998aa73f;-1000", "7196aced-8418-4412-b0ce-4994998aa73f", -1000, 0},
+		{";0;3", "", 0, 3},
+		{"7196aced-8418-4412-b0ce-4994998aa73f;1.5;12", "7196aced-8418-4412-b0ce-4994998aa73f", 1.5, 12},
+	}
+
+	for _, test := range tests {
+		propID, weight, position, err := zoom.ParseEdgeValue(test.val)
+		if err != nil {
+			t.Errorf("ParseEdgeValue(%#v) returned error: %s", test.val, err)
+			continue
+		}
+		if propID != test.propID || weight != test.weight || position != test.position {
+			t.Errorf("ParseEdgeValue(%#v) = %#v, %v, %v, expected %#v, %v, %v", test.val, propID, weight, position, test.propID, test.weight, test.position)
+		}
+		if got := zoom.FormatEdgeValue(propID, weight, position); got != test.val {
+			t.Errorf("FormatEdgeValue(%#v, %v, %v) = %#v, expected %#v", propID, weight, position, got, test.val)
+		}
+	}
+
+	for _, invalid := range []string{"abc;x", ";1;x", ";1;-2", ";1;2;3"} {
+		if _, _, _, err := zoom.ParseEdgeValue(invalid); err == nil {
+			t.Errorf("expected error for invalid edge value %#v", invalid)
+		}
+	}
+}
+
+func TestEdgeRemoveKeepsPropertyNode(t *testing.T) {
+	store := memstore.New("shard1")
+
+	a := zoom.NewNode(store, "")
+	b := zoom.NewNode(store, "")
+
+	if err := a.NewEdge("knows", b, map[string]interface{}{"Since": "1934"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := a.GetEdge("knows", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if edges, _ := store.GetEdges("knows", a.ID()); len(edges) != 0 {
+		t.Errorf("edges after Remove = %v, expected none", edges)
+	}
+
+	if _, err := store.GetNodeProperties(e.Properties.ID(), []string{"Since"}); err != nil {
+		t.Errorf("property node has been removed by Edge.Remove")
+	}
+}
+
+func TestEdgeCategories(t *testing.T) {
+	store := memstore.New("shard1")
+
+	a := zoom.NewNode(store, "")
+	b := zoom.NewNode(store, "")
+
+	for _, cat := range []string{"works", "knows"} {
+		if err := a.NewEdge(cat, b, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cats, err := a.EdgeCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cats) != 2 || cats[0] != "knows" || cats[1] != "works" {
+		t.Errorf("EdgeCategories() = %#v, expected knows and works", cats)
+	}
+}
+
+func TestMultiEdges(t *testing.T) {
+	store := memstore.New("shard1")
+
+	donald := zoom.NewNode(store, "")
+	shop := zoom.NewNode(store, "")
+
+	first, err := donald.AddEdge("paid", shop, map[string]interface{}{"Amount": "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := donald.AddWeightedEdge("paid", shop, 2, map[string]interface{}{"Amount": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.ID == "" || second.ID == "" || first.ID == second.ID {
+		t.Fatalf("parallel edges need distinct ids, got %#v and %#v", first.ID, second.ID)
+	}
+
+	if err := donald.NewEdge("paid", shop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	edges, err := donald.GetEdgesTo("paid", shop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edges) != 3 || edges[0].ID != "" {
+		t.Fatalf("got %d edges, expected 3 with the default edge first", len(edges))
+	}
+
+	if all, _ := donald.GetEdges(store, "paid"); len(all) != 3 {
+		t.Errorf("GetEdges() returned %d edges, expected 3", len(all))
+	}
+
+	if in, _ := shop.GetIncomingEdges(store, "paid"); len(in) != 3 {
+		t.Errorf("GetIncomingEdges() returned %d edges, expected 3", len(in))
+	}
+
+	for _, e := range edges[1:] {
+		if err := e.Properties.LoadProperties([]string{"Amount"}); err != nil {
+			t.Fatal(err)
+		}
+		if e.ID == second.ID && (e.Properties.GetString("Amount") != "7" || e.Weight != 2) {
+			t.Errorf("second edge: Amount %#v, weight %v", e.Properties.GetString("Amount"), e.Weight)
+		}
+	}
+
+	if err := donald.RemoveEdgeByID("paid", shop, first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetNodeProperties(first.Properties.ID(), []string{"Amount"}); err == nil {
+		t.Errorf("property node of removed edge still exists")
+	}
+
+	edges, err = donald.GetEdgesTo("paid", shop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edges) != 2 {
+		t.Errorf("got %d edges after RemoveEdgeByID, expected 2", len(edges))
+	}
+
+	if err := donald.RemoveEdge("paid", shop); err != nil {
+		t.Fatal(err)
+	}
+
+	if edges, _ := donald.GetEdgesTo("paid", shop); len(edges) != 0 {
+		t.Errorf("got %d edges after RemoveEdge, expected none", len(edges))
+	}
+
+	if _, err := store.GetNodeProperties(second.Properties.ID(), []string{"Amount"}); err == nil {
+		t.Errorf("property node of second edge still exists")
+	}
+}
+
+func TestNewEdgeReplacesPropertyNode(t *testing.T) {
+	store := memstore.New("shard1")
+
+	a := zoom.NewNode(store, "")
+	b := zoom.NewNode(store, "")
+
+	if err := a.NewEdge("knows", b, map[string]interface{}{"Since": "1934"}); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := a.GetEdge("knows", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.NewEdge("knows", b, map[string]interface{}{"Since": "1937"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetNodeProperties(old.Properties.ID(), []string{"Since"}); err == nil {
+		t.Errorf("replaced property node still exists")
+	}
+}
@@ -0,0 +1,524 @@
+package zoom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/*
+	full-text search
+
+	A FullText index maps the words of node texts to the uuids of the nodes. Like the indexes of
+	index.go it is maintained by a Store that is wrapped via FullText.Wrap and saved via the
+	IndexStore of the wrapped store (outside of the repository) when the transaction is committed.
+
+	Texts are split into words at every rune that is neither a letter nor a digit and the words are
+	lowercased. A query is a list of words separated by whitespace; a node matches, if each
+	word of the query is found in one of its indexed texts. A word ending with * matches all words
+	with the given prefix.
+
+	The index is split into fullTextBuckets files below Path, named after the hash of the uuid
+	(see bucketOf). Each file is a JSON map of uuid => textname => words, so that a commit only
+	rewrites the files of the changed nodes. Like the indexes of index.go, the changed files are
+	saved to temporary files before the data is committed and renamed afterwards. If a temporary
+	file is found when the index is loaded, the documents of the nodes in the file and in the
+	temporary file are read again from the committed texts (see FullText.recover).
+*/
+
+// fullTextBuckets is the number of files of a full-text index
+const fullTextBuckets = 256
+
+// bucketOf returns the name of the file of the full-text index for the given uuid
+func bucketOf(uuid string) string {
+	h := fnv.New32a()
+	h.Write([]byte(uuid))
+	return fmt.Sprintf("%02x", h.Sum32()%fullTextBuckets)
+}
+
+// splitWords returns the lowercased words of the text in order
+func splitWords(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, f := range fields {
+		fields[i] = strings.ToLower(f)
+	}
+	return fields
+}
+
+// Tokenize returns the sorted distinct lowercased words of a text, as they are indexed by FullText
+func Tokenize(text string) []string {
+	seen := map[string]bool{}
+	var words []string
+	for _, w := range splitWords(text) {
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// FullText is the full-text index over the texts of the nodes of one shard.
+// It must be used for all transactions of the shard, so that the index stays up to date.
+type FullText struct {
+	Path  string
+	texts map[string]bool // indexed text names, all texts if empty
+
+	mx      sync.Mutex
+	buckets map[string]map[string]map[string][]string // committed bucket => uuid => textname => words
+	words   map[string]map[string]bool                // committed word => uuids
+}
+
+// NewFullText defines a full-text index that is saved in path. If texts are given,
+// only the texts with the given names are indexed.
+func NewFullText(path string, texts ...string) *FullText {
+	ft := &FullText{Path: path, texts: map[string]bool{}}
+	for _, t := range texts {
+		ft.texts[t] = true
+	}
+	return ft
+}
+
+func (ft *FullText) isIndexed(text string) bool {
+	return len(ft.texts) == 0 || ft.texts[text]
+}
+
+// Wrap returns a store that keeps the full-text index up to date, whenever texts are saved or nodes
+// are removed via the returned store. The wrapped store must implement IndexStore.
+func (ft *FullText) Wrap(st Store) Store {
+	return &fullTextStore{Store: st, ft: ft, staged: map[string]map[string][]string{}}
+}
+
+func (ft *FullText) bucketPath(bucket string) string {
+	return ft.Path + "/" + bucket
+}
+
+// doc returns the committed document of the node
+func (ft *FullText) doc(uuid string) map[string][]string {
+	return ft.buckets[bucketOf(uuid)][uuid]
+}
+
+func (ft *FullText) addWords(uuid string, doc map[string][]string) {
+	for _, words := range doc {
+		for _, w := range words {
+			if ft.words[w] == nil {
+				ft.words[w] = map[string]bool{}
+			}
+			ft.words[w][uuid] = true
+		}
+	}
+}
+
+func (ft *FullText) removeWords(uuid string, doc map[string][]string) {
+	for _, words := range doc {
+		for _, w := range words {
+			delete(ft.words[w], uuid)
+			if len(ft.words[w]) == 0 {
+				delete(ft.words, w)
+			}
+		}
+	}
+}
+
+// readDocs reads the documents of a file of the index, has is false, if the file does not exist
+func readDocs(is IndexStore, path string) (docs map[string]map[string][]string, has bool, err error) {
+	docs = map[string]map[string][]string{}
+	err = is.GetIndex(path, func(rd io.Reader) error {
+		has = true
+		return json.NewDecoder(rd).Decode(&docs)
+	})
+	return
+}
+
+func encodeDocs(docs map[string]map[string][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(docs)
+	return buf.Bytes(), err
+}
+
+// load loads the committed index, if not already done
+func (ft *FullText) load(st Store) error {
+	if ft.buckets != nil {
+		return nil
+	}
+
+	is, ok := findIndexStore(st)
+	if !ok {
+		return fmt.Errorf("store %T does not support indexes", st)
+	}
+
+	buckets := map[string]map[string]map[string][]string{}
+	for i := 0; i < fullTextBuckets; i++ {
+		bucket := fmt.Sprintf("%02x", i)
+		docs, _, err := readDocs(is, ft.bucketPath(bucket))
+		if err != nil {
+			return err
+		}
+
+		tmpDocs, tmp, err := readDocs(is, tmpIndexPath(ft.bucketPath(bucket)))
+		if err != nil {
+			return err
+		}
+		if tmp {
+			if docs, err = ft.recover(st, is, bucket, docs, tmpDocs); err != nil {
+				return err
+			}
+		}
+
+		if len(docs) > 0 {
+			buckets[bucket] = docs
+		}
+	}
+
+	ft.buckets = buckets
+	ft.words = map[string]map[string]bool{}
+	for _, docs := range buckets {
+		for uuid, doc := range docs {
+			ft.addWords(uuid, doc)
+		}
+	}
+	return nil
+}
+
+// recover returns and saves the documents of a file that has a temporary file. Since it is unknown
+// whether the data has been committed, the documents of the nodes of both files are read again from
+// their texts. Nodes that are in neither of them have not been changed by the interrupted commit.
+func (ft *FullText) recover(st Store, is IndexStore, bucket string, docs, tmpDocs map[string]map[string][]string) (map[string]map[string][]string, error) {
+	names := map[string]map[string]bool{}
+	for _, d := range []map[string]map[string][]string{docs, tmpDocs} {
+		for uuid, doc := range d {
+			if names[uuid] == nil {
+				names[uuid] = map[string]bool{}
+			}
+			for name := range doc {
+				names[uuid][name] = true
+			}
+		}
+	}
+
+	recovered := map[string]map[string][]string{}
+	for uuid, ns := range names {
+		requested := make([]string, 0, len(ns))
+		for name := range ns {
+			requested = append(requested, name)
+		}
+
+		texts, err := st.GetNodeTexts(uuid, requested)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := map[string][]string{}
+		for name, text := range texts {
+			if words := Tokenize(text); len(words) > 0 {
+				doc[name] = words
+			}
+		}
+		if len(doc) > 0 {
+			recovered[uuid] = doc
+		}
+	}
+
+	path := ft.bucketPath(bucket)
+	data, err := encodeDocs(recovered)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveTmpIndexes(is, map[string][]byte{path: data}); err != nil {
+		return nil, err
+	}
+	return recovered, is.RenameIndex(tmpIndexPath(path), path)
+}
+
+// match returns the committed uuids that have a word matching the given query word
+func (ft *FullText) match(word string) map[string]bool {
+	if !strings.HasSuffix(word, "*") {
+		return ft.words[word]
+	}
+
+	prefix := strings.TrimSuffix(word, "*")
+	res := map[string]bool{}
+	for w, uuids := range ft.words {
+		if strings.HasPrefix(w, prefix) {
+			for uuid := range uuids {
+				res[uuid] = true
+			}
+		}
+	}
+	return res
+}
+
+// queryWords splits a query into words, keeping a trailing * for prefix searches
+func queryWords(query string) []string {
+	var words []string
+	for _, f := range strings.Fields(query) {
+		prefix := strings.HasSuffix(f, "*")
+		toks := splitWords(strings.TrimSuffix(f, "*"))
+		if len(toks) == 0 {
+			continue
+		}
+		if prefix {
+			// only the last word of e.g. "foo-ba*" is a prefix
+			toks[len(toks)-1] += "*"
+		}
+		words = append(words, toks...)
+	}
+	return words
+}
+
+func docMatches(doc map[string][]string, word string) bool {
+	prefix := strings.HasSuffix(word, "*")
+	word = strings.TrimSuffix(word, "*")
+	for _, words := range doc {
+		for _, w := range words {
+			if w == word || (prefix && strings.HasPrefix(w, word)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type fullTextStore struct {
+	Store
+	ft     *FullText
+	staged map[string]map[string][]string // changed documents by uuid, nil for removed nodes
+}
+
+func (s *fullTextStore) Unwrap() Store {
+	return s.Store
+}
+
+// doc returns a copy of the current document of the node
+func (s *fullTextStore) doc(uuid string) (map[string][]string, error) {
+	var doc map[string][]string
+
+	if d, has := s.staged[uuid]; has {
+		doc = d
+	} else {
+		s.ft.mx.Lock()
+		defer s.ft.mx.Unlock()
+
+		if err := s.ft.load(s.Store); err != nil {
+			return nil, err
+		}
+		doc = s.ft.doc(uuid)
+	}
+
+	c := make(map[string][]string, len(doc))
+	for k, v := range doc {
+		c[k] = v
+	}
+	return c, nil
+}
+
+func (s *fullTextStore) SaveNodeTexts(uuid string, texts map[string]string) error {
+	doc, err := s.doc(uuid)
+	if err != nil {
+		return err
+	}
+
+	var changed bool
+	for name, text := range texts {
+		if !s.ft.isIndexed(name) {
+			continue
+		}
+		changed = true
+		words := Tokenize(text)
+		if len(words) == 0 {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = words
+	}
+
+	if err := s.Store.SaveNodeTexts(uuid, texts); err != nil {
+		return err
+	}
+
+	if changed {
+		s.staged[uuid] = doc
+	}
+	return nil
+}
+
+func (s *fullTextStore) RemoveNode(uuid string) error {
+	if err := s.Store.RemoveNode(uuid); err != nil {
+		return err
+	}
+	s.staged[uuid] = nil
+	return nil
+}
+
+// RemoveEdges removes the property nodes of the edges from the full text index, since the store removes them
+func (s *fullTextStore) RemoveEdges(category, fromUUID string) error {
+	propIDs, err := edgePropertyNodes(s.Store, category, fromUUID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store.RemoveEdges(category, fromUUID); err != nil {
+		return err
+	}
+
+	for _, propID := range propIDs {
+		s.staged[propID] = nil
+	}
+	return nil
+}
+
+// Search returns the sorted uuids of the nodes which texts contain all words of the query
+func (s *fullTextStore) Search(query string) ([]string, error) {
+	words := queryWords(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	s.ft.mx.Lock()
+	defer s.ft.mx.Unlock()
+
+	if err := s.ft.load(s.Store); err != nil {
+		return nil, err
+	}
+
+	var found map[string]bool
+	for _, w := range words {
+		m := s.ft.match(w)
+		res := map[string]bool{}
+		for uuid := range m {
+			if found == nil || found[uuid] {
+				res[uuid] = true
+			}
+		}
+		found = res
+	}
+
+	// staged documents replace the committed ones
+	for uuid, doc := range s.staged {
+		delete(found, uuid)
+		if doc == nil {
+			continue
+		}
+		all := true
+		for _, w := range words {
+			if !docMatches(doc, w) {
+				all = false
+				break
+			}
+		}
+		if all {
+			found[uuid] = true
+		}
+	}
+
+	uuids := make([]string, 0, len(found))
+	for uuid := range found {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	return uuids, nil
+}
+
+// Commit saves the files of the changed nodes to their temporary files, commits the data and renames the
+// temporary files. The committed index in memory is only changed after the data has been committed.
+func (s *fullTextStore) Commit(msg CommitMessage) error {
+	if len(s.staged) == 0 {
+		return s.Store.Commit(msg)
+	}
+
+	is, ok := findIndexStore(s.Store)
+	if !ok {
+		return fmt.Errorf("store %T does not support indexes", s.Store)
+	}
+
+	s.ft.mx.Lock()
+	defer s.ft.mx.Unlock()
+
+	if err := s.ft.load(s.Store); err != nil {
+		return err
+	}
+
+	// copies of the changed buckets with the staged documents
+	buckets := map[string]map[string]map[string][]string{}
+	for uuid, doc := range s.staged {
+		bucket := bucketOf(uuid)
+		if buckets[bucket] == nil {
+			docs := map[string]map[string][]string{}
+			for id, d := range s.ft.buckets[bucket] {
+				docs[id] = d
+			}
+			buckets[bucket] = docs
+		}
+		if len(doc) == 0 {
+			delete(buckets[bucket], uuid)
+			continue
+		}
+		buckets[bucket][uuid] = doc
+	}
+
+	data := make(map[string][]byte, len(buckets))
+	paths := make([]string, 0, len(buckets))
+	for bucket, docs := range buckets {
+		b, err := encodeDocs(docs)
+		if err != nil {
+			return err
+		}
+		path := s.ft.bucketPath(bucket)
+		data[path] = b
+		paths = append(paths, path)
+	}
+
+	if err := saveTmpIndexes(is, data); err != nil {
+		return err
+	}
+
+	if err := s.Store.Commit(msg); err != nil {
+		return err
+	}
+
+	for uuid, doc := range s.staged {
+		s.ft.removeWords(uuid, s.ft.doc(uuid))
+		s.ft.addWords(uuid, doc)
+	}
+	for bucket, docs := range buckets {
+		s.ft.buckets[bucket] = docs
+	}
+	s.staged = map[string]map[string][]string{}
+	renameTmpIndexes(is, paths)
+	return nil
+}
+
+func (s *fullTextStore) Rollback() error {
+	s.staged = map[string]map[string][]string{}
+	return s.Store.Rollback()
+}
+
+// Searcher is implemented by stores that support full-text search
+type Searcher interface {
+	Search(query string) (uuids []string, err error)
+}
+
+// Search returns the sorted uuids of the nodes which texts contain all words of the query.
+// The transaction must be a store that has been wrapped by FullText.Wrap.
+func Search(tr Transaction, query string) ([]string, error) {
+	var st interface{} = tr
+	for {
+		if s, ok := st.(Searcher); ok {
+			return s.Search(query)
+		}
+		u, ok := st.(Unwrapper)
+		if !ok {
+			return nil, fmt.Errorf("transaction %T has no full-text index", tr)
+		}
+		st = u.Unwrap()
+	}
+}
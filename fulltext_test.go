@@ -0,0 +1,233 @@
+package zoom_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func TestTokenize(t *testing.T) {
+	got := zoom.Tokenize("Donald Duck lives in Duckburg, donald's nephews: Tick, Trick & Track.")
+	expected := []string{"donald", "duck", "duckburg", "in", "lives", "nephews", "s", "tick", "track", "trick"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Tokenize() = %#v, expected %#v", got, expected)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	mem := memstore.New("shard1")
+	ft := zoom.NewFullText("fulltext", "Bio")
+
+	transaction := func(action func(zoom.Transaction) error) error {
+		return zoom.NewTransaction(ft.Wrap(mem), zoom.CommitMessage{Command: "test"}, action)
+	}
+
+	search := func(query string) []string {
+		var uuids []string
+		err := transaction(func(tr zoom.Transaction) (err error) {
+			uuids, err = zoom.Search(tr, query)
+			if err != nil {
+				return err
+			}
+			return zoom.ErrNoCommit
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuids
+	}
+
+	var donald, daisy string
+
+	err := transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, "")
+		donald = d.ID()
+		d.SetText("Bio", "Donald Duck lives in Duckburg.")
+		d.SetText("Secret", "hidden treasure")
+		if err := d.Save(); err != nil {
+			return err
+		}
+
+		d = zoom.NewNode(tr, "")
+		daisy = d.ID()
+		d.SetText("Bio", "Daisy Duck is the girlfriend of Donald.")
+		if err := d.Save(); err != nil {
+			return err
+		}
+
+		// staged changes are visible inside the transaction
+		found, err := zoom.Search(tr, "daisy")
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(found, []string{daisy}) {
+			t.Errorf("search daisy inside transaction = %v, expected %v", found, []string{daisy})
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	both := []string{donald, daisy}
+	sort.Strings(both)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"duck", both},
+		{"DUCK donald", both},
+		{"duckburg", []string{donald}},
+		{"duck*", both},
+		{"girl*", []string{daisy}},
+		{"duck daisy", []string{daisy}},
+		{"treasure", []string{}},
+		{"mickey", []string{}},
+	}
+
+	for _, test := range tests {
+		if got := search(test.query); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("search %#v = %v, expected %v", test.query, got, test.expected)
+		}
+	}
+
+	// changes of a rolled back transaction are not indexed
+	err = transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, donald)
+		d.SetText("Bio", "Mickey Mouse")
+		if err := d.Save(); err != nil {
+			return err
+		}
+		return zoom.ErrNoCommit
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search("mickey"); len(got) != 0 {
+		t.Errorf("search mickey after rollback = %v, expected none", got)
+	}
+
+	err = transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, donald)
+		d.SetText("Bio", "Donald is a sailor")
+		return d.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search("duckburg"); len(got) != 0 {
+		t.Errorf("search duckburg after change = %v, expected none", got)
+	}
+
+	if got := search("sailor"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("search sailor = %v, expected %v", got, []string{donald})
+	}
+
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveNode(daisy)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search("donald"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("search donald after remove = %v, expected %v", got, []string{donald})
+	}
+
+	// the index is persisted in the store
+	fresh := zoom.NewFullText("fulltext", "Bio")
+	var got []string
+	err = zoom.NewTransaction(fresh.Wrap(mem), zoom.CommitMessage{}, func(tr zoom.Transaction) (err error) {
+		got, err = zoom.Search(tr, "sailor")
+		return
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("persisted search sailor = %v, expected %v", got, []string{donald})
+	}
+}
+
+func TestSearchCommit(t *testing.T) {
+	mem := memstore.New("shard1")
+	broken := &brokenIndexStore{Store: mem, mem: mem}
+	ft := zoom.NewFullText("fulltext", "Bio")
+
+	save := func(uuid, bio string) error {
+		return zoom.NewTransaction(ft.Wrap(broken), zoom.CommitMessage{}, func(tr zoom.Transaction) error {
+			d := zoom.NewNode(tr, uuid)
+			d.SetText("Bio", bio)
+			return d.Save()
+		})
+	}
+
+	search := func(ft *zoom.FullText, query string) []string {
+		var uuids []string
+		err := zoom.NewTransaction(ft.Wrap(mem), zoom.CommitMessage{}, func(tr zoom.Transaction) (err error) {
+			uuids, err = zoom.Search(tr, query)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuids
+	}
+
+	donald := "0e5c1e2a-a4f7-4b4e-9d3b-7d5b8a1f2c3d"
+	daisy := "5a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+
+	if err := save(donald, "Donald lives in Duckburg"); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the file of the changed node is written
+	broken.saves = 0
+	if err := save(daisy, "Daisy lives in Duckburg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if broken.saves != 1 {
+		t.Errorf("saved %d index files, expected 1", broken.saves)
+	}
+
+	// the index in memory is not changed, if the data is not committed
+	broken.failCommit = true
+	if err := save(donald, "Donald is a sailor"); err == nil {
+		t.Fatal("expected commit error")
+	}
+	broken.failCommit = false
+
+	if got := search(ft, "sailor"); len(got) != 0 {
+		t.Errorf("search sailor after failed commit = %v, expected none", got)
+	}
+
+	// the index is recovered from the texts, if the temporary file could not be renamed
+	broken.failRename = true
+	if err := save(daisy, "Daisy is a sailor"); err != nil {
+		t.Fatalf("commit with failing rename: %v", err)
+	}
+	broken.failRename = false
+
+	fresh := zoom.NewFullText("fulltext", "Bio")
+	if got := search(fresh, "sailor"); !reflect.DeepEqual(got, []string{daisy}) {
+		t.Errorf("search sailor after recovery = %v, expected %v", got, []string{daisy})
+	}
+
+	if got := search(fresh, "duckburg"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("search duckburg after recovery = %v, expected %v", got, []string{donald})
+	}
+}
@@ -0,0 +1,178 @@
+package gitstore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/codec"
+)
+
+/*
+	batch loading
+
+	Store and Snapshot implement zoom.BatchLoader by reading all requested files with one
+	`git cat-file --batch` run instead of one git run per file. The files are given as git objects,
+	":path" for the staged file of a Store and "commit:path" for the file of a Snapshot.
+
+	A Store reads single files the same way (see Store.readFile), so the batch and the single reads both
+	read the files from the git index, where the transaction stages its changes (Rollback resets the index
+	to HEAD). A transaction therefore sees the nodes it has saved, but not yet committed.
+*/
+
+var (
+	_ zoom.BatchLoader = &Store{}
+	_ zoom.BatchLoader = &Snapshot{}
+)
+
+// catFiles reads the given objects with one git cat-file --batch run and calls fn with the
+// index and the content of each existing object
+func catFiles(gitDir string, objects []string, fn func(i int, content []byte) error) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "--git-dir="+gitDir, "cat-file", "--batch")
+	cmd.Dir = filepath.Dir(gitDir)
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git cat-file --batch: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	rd := bufio.NewReader(&stdout)
+	for i, object := range objects {
+		header, err := rd.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("git cat-file --batch: missing output for %#v", object)
+		}
+
+		// "<object> missing" for objects that do not exist, "<sha> <type> <size>" otherwise
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("git cat-file --batch: invalid header %#v for %#v", header, object)
+		}
+
+		content := make([]byte, size+1) // with the trailing newline
+		if _, err := io.ReadFull(rd, content); err != nil {
+			return fmt.Errorf("git cat-file --batch: %s for %#v", err, object)
+		}
+
+		if err := fn(i, content[:size]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile writes the staged content of the file with the given path to wr
+func (g *Store) readFile(path string, wr io.Writer) error {
+	var found bool
+	err := catFiles(g.Git.Dir, []string{":" + path}, func(i int, content []byte) error {
+		found = true
+		_, err := wr.Write(content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("file %s does not exist", path)
+	}
+	return nil
+}
+
+// batchProperties returns the requested properties of the nodes with the given property files (as git objects)
+func batchProperties(gitDir string, uuids, objects []string, requestedProps []string) (map[string]map[string]interface{}, error) {
+	props := map[string]map[string]interface{}{}
+	err := catFiles(gitDir, objects, func(i int, content []byte) error {
+		data := map[string]interface{}{}
+		if _, err := codec.Read(bytes.NewReader(content), &data); err != nil {
+			return err
+		}
+		orig, err := codec.DecodeProperties(data)
+		if err != nil {
+			return err
+		}
+
+		p := map[string]interface{}{}
+		for _, req := range requestedProps {
+			if v, has := orig[req]; has {
+				p[req] = v
+			}
+		}
+		props[uuids[i]] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// batchTexts returns the requested texts of the nodes, textPath returns the git object of a text file
+func batchTexts(gitDir string, uuids []string, requestedTexts []string, textPath func(uuid, text string) string) (map[string]map[string]string, error) {
+	texts := make(map[string]map[string]string, len(uuids))
+	objects := make([]string, 0, len(uuids)*len(requestedTexts))
+	for _, uuid := range uuids {
+		texts[uuid] = map[string]string{}
+		for _, text := range requestedTexts {
+			objects = append(objects, textPath(uuid, text))
+		}
+	}
+
+	err := catFiles(gitDir, objects, func(i int, content []byte) error {
+		uuid, text := uuids[i/len(requestedTexts)], requestedTexts[i%len(requestedTexts)]
+		texts[uuid][text] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
+
+// GetNodesProperties returns the requested properties of the existing nodes
+func (g *Store) GetNodesProperties(uuids []string, requestedProps []string) (props map[string]map[string]interface{}, err error) {
+	objects := make([]string, len(uuids))
+	for i, uuid := range uuids {
+		objects[i] = ":" + g.propPath(uuid)
+	}
+	return batchProperties(g.Git.Dir, uuids, objects, requestedProps)
+}
+
+// GetNodesTexts returns the requested texts of the nodes
+func (g *Store) GetNodesTexts(uuids []string, requestedTexts []string) (texts map[string]map[string]string, err error) {
+	return batchTexts(g.Git.Dir, uuids, requestedTexts, func(uuid, text string) string {
+		return ":" + g.textPath(uuid, text)
+	})
+}
+
+// GetNodesProperties returns the requested properties of the nodes that exist at the commit of the snapshot
+func (s *Snapshot) GetNodesProperties(uuids []string, requestedProps []string) (props map[string]map[string]interface{}, err error) {
+	objects := make([]string, len(uuids))
+	for i, uuid := range uuids {
+		objects[i] = s.commit + ":" + s.paths.propPath(uuid)
+	}
+	return batchProperties(s.git.Dir, uuids, objects, requestedProps)
+}
+
+// GetNodesTexts returns the requested texts of the nodes at the commit of the snapshot
+func (s *Snapshot) GetNodesTexts(uuids []string, requestedTexts []string) (texts map[string]map[string]string, err error) {
+	return batchTexts(s.git.Dir, uuids, requestedTexts, func(uuid, text string) string {
+		return s.commit + ":" + s.paths.textPath(uuid, text)
+	})
+}
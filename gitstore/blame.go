@@ -0,0 +1,86 @@
+package gitstore
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Blame is the last change of each property and text of a node (see Git.Blame)
+type Blame struct {
+	UUID       string
+	Properties map[string]LogEntry // property key => commit of the last change
+	Texts      map[string]LogEntry // text name => commit of the last change
+}
+
+// Blame returns for each current property and text of the node the commit that has changed it last.
+// The history of the node files is walked from the first commit on and the decoded values of each
+// commit are compared with the values of the previous one. Properties and texts that have been removed
+// are left out, so the maps are empty for a removed node.
+func (g *Git) Blame(uuid string) (*Blame, error) {
+	if len(uuid) < 3 {
+		return nil, fmt.Errorf("invalid uuid %#v", uuid)
+	}
+
+	p := &Store{shard: g.shard}
+	commits, err := g.log([]string{"--reverse"}, []string{p.propPath(uuid), g.textDir(uuid)})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Blame{UUID: uuid, Properties: map[string]LogEntry{}, Texts: map[string]LogEntry{}}
+	props := map[string]interface{}{}
+	texts := map[string]string{}
+
+	for _, c := range commits {
+		snap := g.snapshot(c.Commit)
+
+		nextProps, err := snap.allProperties(uuid)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range nextProps {
+			if old, has := props[k]; !has || !reflect.DeepEqual(old, v) {
+				b.Properties[k] = c
+			}
+		}
+		for k := range props {
+			if _, has := nextProps[k]; !has {
+				delete(b.Properties, k)
+			}
+		}
+		props = nextProps
+
+		nextTexts, err := snap.allTexts(uuid)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range nextTexts {
+			if old, has := texts[k]; !has || old != v {
+				b.Texts[k] = c
+			}
+		}
+		for k := range texts {
+			if _, has := nextTexts[k]; !has {
+				delete(b.Texts, k)
+			}
+		}
+		texts = nextTexts
+	}
+	return b, nil
+}
+
+// allTexts returns all texts of the node at the snapshot
+func (s *Snapshot) allTexts(uuid string) (map[string]string, error) {
+	dir := s.git.textDir(uuid) + "/"
+	files, err := s.lsFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = strings.TrimPrefix(file, dir)
+	}
+	return s.GetNodeTexts(uuid, names)
+}
@@ -0,0 +1,89 @@
+package gitstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestBlame(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id string
+	err = git.Transaction(zoom.CommitMessage{User: "admin", Command: "create"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("Name", "Donald")
+		n.SetInt("Age", 44)
+		n.SetString("City", "Duckburg")
+		n.SetText("Bio", "a duck")
+		id = n.ID()
+		return n.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{User: "donald", Command: "edit"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.SetString("Name", "Donald Duck")
+		// same value, no change
+		n.SetInt("Age", 44)
+		n.SetText("Notes", "likes boats")
+		return n.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{User: "daisy", Command: "edit"}, func(tr zoom.Transaction) error {
+		if err := tr.SaveNodeProperties(id, map[string]interface{}{"City": nil}); err != nil {
+			return err
+		}
+		n := zoom.NewNode(tr, id)
+		n.SetText("Bio", "a famous duck")
+		return n.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := git.Blame(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := map[string]string{"Name": "donald", "Age": "admin"}
+	if len(b.Properties) != len(props) {
+		t.Errorf("got blame for %d properties, expected %d: %#v", len(b.Properties), len(props), b.Properties)
+	}
+	for k, user := range props {
+		if got := b.Properties[k].Message.User; got != user {
+			t.Errorf("property %s last changed by %#v, expected %#v", k, got, user)
+		}
+	}
+
+	texts := map[string]string{"Bio": "daisy", "Notes": "donald"}
+	if len(b.Texts) != len(texts) {
+		t.Errorf("got blame for %d texts, expected %d: %#v", len(b.Texts), len(texts), b.Texts)
+	}
+	for k, user := range texts {
+		if got := b.Texts[k].Message.User; got != user {
+			t.Errorf("text %s last changed by %#v, expected %#v", k, got, user)
+		}
+	}
+
+	if b.Properties["Name"].Commit == "" || b.Properties["Name"].Time.IsZero() {
+		t.Errorf("missing commit or time: %#v", b.Properties["Name"])
+	}
+}
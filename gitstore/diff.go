@@ -0,0 +1,214 @@
+package gitstore
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/metakeule/zoom/codec"
+)
+
+/*
+	diff
+
+	Git.Diff compares the node, text and edge files of the shard at two commits and returns the changes
+	as nodes, properties, texts and edges instead of changed files. Incoming edges are left out, since
+	they are the reverse of the edges.
+*/
+
+// PropertyChange is a changed property of a node. Old is nil for added and New is nil for removed properties.
+type PropertyChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// TextChange is a changed text of a node. Old is empty for added and New is empty for removed texts.
+type TextChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// NodeChange are the changes of a node
+type NodeChange struct {
+	UUID       string
+	Created    bool
+	Removed    bool
+	Properties []PropertyChange // sorted by key
+	Texts      []TextChange     // sorted by name
+}
+
+// EdgeChange is an added, removed or changed edge (see zoom.EdgeKey and zoom.FormatEdgeValue)
+type EdgeChange struct {
+	Category string
+	From     string // uuid of the source node
+	Key      string // key of the edge inside the edges file
+	Added    bool
+	Removed  bool
+	Old      string // value before, if not added
+	New      string // value after, if not removed
+}
+
+// Changeset are the changes of a shard between two commits
+type Changeset struct {
+	From  string       // sha of the older commit
+	To    string       // sha of the newer commit
+	Nodes []NodeChange // sorted by uuid
+	Edges []EdgeChange // sorted by category, source and key
+}
+
+// Diff returns the changes of the shard from the revision fromRev to the revision toRev (see At)
+func (g *Git) Diff(fromRev, toRev string) (*Changeset, error) {
+	from, err := g.At(fromRev)
+	if err != nil {
+		return nil, err
+	}
+	to, err := g.At(toRev)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := g.run("diff-tree", "-r", "-z", "--no-renames", "--name-status", from.commit, to.commit, "--", "node", "text", "refs")
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &Changeset{From: from.commit, To: to.commit}
+	nodes := map[string]*NodeChange{}
+	node := func(uuid string) *NodeChange {
+		if nodes[uuid] == nil {
+			nodes[uuid] = &NodeChange{UUID: uuid}
+		}
+		return nodes[uuid]
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], fields[i+1]
+		parts := strings.Split(path, "/")
+
+		switch {
+		case parts[0] == "node" && len(parts) == 4 && parts[1] == g.shard:
+			nc := node(parts[2] + parts[3])
+			nc.Created = status == "A"
+			nc.Removed = status == "D"
+			nc.Properties, err = diffProperties(from, to, nc.UUID)
+		case parts[0] == "text" && len(parts) >= 5 && parts[1] == g.shard:
+			nc := node(parts[2] + parts[3])
+			var tc *TextChange
+			tc, err = diffText(from, to, nc.UUID, strings.Join(parts[4:], "/"))
+			if tc != nil {
+				nc.Texts = append(nc.Texts, *tc)
+			}
+		case parts[0] == "refs" && len(parts) >= 5 && parts[len(parts)-3] == g.shard:
+			var ec []EdgeChange
+			ec, err = diffEdges(from, to, strings.Join(parts[1:len(parts)-3], "/"), parts[len(parts)-2]+parts[len(parts)-1])
+			cs.Edges = append(cs.Edges, ec...)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, nc := range nodes {
+		sort.Slice(nc.Texts, func(i, j int) bool { return nc.Texts[i].Name < nc.Texts[j].Name })
+		cs.Nodes = append(cs.Nodes, *nc)
+	}
+	sort.Slice(cs.Nodes, func(i, j int) bool { return cs.Nodes[i].UUID < cs.Nodes[j].UUID })
+	sort.Slice(cs.Edges, func(i, j int) bool {
+		a, b := cs.Edges[i], cs.Edges[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.Key < b.Key
+	})
+	return cs, nil
+}
+
+// allProperties returns all properties of the node at the snapshot, an empty map if the node does not exist
+func (s *Snapshot) allProperties(uuid string) (map[string]interface{}, error) {
+	path := s.paths.propPath(uuid)
+	known, err := s.isFileKnown(path)
+	if err != nil || !known {
+		return map[string]interface{}{}, err
+	}
+
+	data := map[string]interface{}{}
+	if err := s.load(path, &data); err != nil {
+		return nil, err
+	}
+	return codec.DecodeProperties(data)
+}
+
+func diffProperties(from, to *Snapshot, uuid string) ([]PropertyChange, error) {
+	before, err := from.allProperties(uuid)
+	if err != nil {
+		return nil, err
+	}
+	after, err := to.allProperties(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []PropertyChange
+	for k, old := range before {
+		if n, has := after[k]; !has || !reflect.DeepEqual(old, n) {
+			changes = append(changes, PropertyChange{Key: k, Old: old, New: after[k]})
+		}
+	}
+	for k, n := range after {
+		if _, has := before[k]; !has {
+			changes = append(changes, PropertyChange{Key: k, New: n})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+func diffText(from, to *Snapshot, uuid, name string) (*TextChange, error) {
+	before, err := from.GetNodeTexts(uuid, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	after, err := to.GetNodeTexts(uuid, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if before[name] == after[name] {
+		return nil, nil
+	}
+	return &TextChange{Name: name, Old: before[name], New: after[name]}, nil
+}
+
+func diffEdges(from, to *Snapshot, category, uuid string) ([]EdgeChange, error) {
+	before, err := from.GetEdges(category, uuid)
+	if err != nil {
+		return nil, err
+	}
+	after, err := to.GetEdges(category, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []EdgeChange
+	for key, old := range before {
+		n, has := after[key]
+		switch {
+		case !has:
+			changes = append(changes, EdgeChange{Category: category, From: uuid, Key: key, Removed: true, Old: old})
+		case n != old:
+			changes = append(changes, EdgeChange{Category: category, From: uuid, Key: key, Old: old, New: n})
+		}
+	}
+	for key, n := range after {
+		if _, has := before[key]; !has {
+			changes = append(changes, EdgeChange{Category: category, From: uuid, Key: key, Added: true, New: n})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,149 @@
+package gitstore
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var donald, daisy, gustav string
+	err = git.Transaction(zoom.CommitMessage{Command: "create"}, func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, "")
+		d.SetString("Name", "Donald")
+		d.SetInt("Age", 44)
+		d.SetText("Bio", "a duck")
+		donald = d.ID()
+		if err := d.Save(); err != nil {
+			return err
+		}
+
+		g := zoom.NewNode(tr, "")
+		g.SetString("Name", "Gustav")
+		gustav = g.ID()
+		if err := g.Save(); err != nil {
+			return err
+		}
+		return d.NewEdge("knows", g, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := git.Tag("before"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{Command: "change"}, func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, donald)
+		d.SetString("Name", "Donald Duck")
+		d.SetText("Bio", "a famous duck")
+		if err := d.Save(); err != nil {
+			return err
+		}
+		if err := tr.SaveNodeProperties(donald, map[string]interface{}{"Age": nil}); err != nil {
+			return err
+		}
+
+		dy := zoom.NewNode(tr, "")
+		dy.SetString("Name", "Daisy")
+		daisy = dy.ID()
+		if err := dy.Save(); err != nil {
+			return err
+		}
+		if err := d.NewWeightedEdge("knows", dy, 2, nil); err != nil {
+			return err
+		}
+		if err := d.RemoveEdge("knows", zoom.NewNode(tr, gustav)); err != nil {
+			return err
+		}
+		return zoom.NewNode(tr, gustav).Remove()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := git.Diff("before", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := map[string]NodeChange{}
+	for _, nc := range cs.Nodes {
+		nodes[nc.UUID] = nc
+	}
+
+	if len(nodes) != 3 {
+		t.Fatalf("got %d changed nodes, expected 3", len(nodes))
+	}
+
+	if !nodes[daisy].Created || nodes[daisy].Removed {
+		t.Errorf("daisy should be created: %#v", nodes[daisy])
+	}
+
+	if !nodes[gustav].Removed || nodes[gustav].Created {
+		t.Errorf("gustav should be removed: %#v", nodes[gustav])
+	}
+
+	d := nodes[donald]
+	if d.Created || d.Removed {
+		t.Errorf("donald should be changed only: %#v", d)
+	}
+
+	expectedProps := []PropertyChange{
+		{Key: "Age", Old: int64(44)},
+		{Key: "Name", Old: "Donald", New: "Donald Duck"},
+	}
+	if !reflect.DeepEqual(d.Properties, expectedProps) {
+		t.Errorf("donald properties = %#v, expected %#v", d.Properties, expectedProps)
+	}
+
+	expectedTexts := []TextChange{{Name: "Bio", Old: "a duck", New: "a famous duck"}}
+	if !reflect.DeepEqual(d.Texts, expectedTexts) {
+		t.Errorf("donald texts = %#v, expected %#v", d.Texts, expectedTexts)
+	}
+
+	if len(cs.Edges) != 2 {
+		t.Fatalf("got %d changed edges, expected 2: %#v", len(cs.Edges), cs.Edges)
+	}
+
+	for _, e := range cs.Edges {
+		if e.Category != "knows" || e.From != donald {
+			t.Errorf("unexpected edge change %#v", e)
+		}
+		switch e.Key {
+		case "shard1-" + daisy:
+			if !e.Added || e.New != zoom.FormatEdgeValue("", 2, 0) {
+				t.Errorf("edge to daisy should be added with weight 2: %#v", e)
+			}
+		case "shard1-" + gustav:
+			if !e.Removed {
+				t.Errorf("edge to gustav should be removed: %#v", e)
+			}
+		default:
+			t.Errorf("unexpected edge change %#v", e)
+		}
+	}
+
+	cs, err = git.Diff("master", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.Nodes)+len(cs.Edges) != 0 {
+		t.Errorf("diff of the same commit should be empty: %#v", cs)
+	}
+}
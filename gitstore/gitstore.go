@@ -2,16 +2,17 @@ package gitstore
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/metakeule/gitlib"
 	"github.com/metakeule/zoom"
-	// "gopkg.in/vmihailenco/msgpack.v1"
+	"github.com/metakeule/zoom/codec"
 )
 
 /*
@@ -32,10 +33,28 @@ func FileExists(name string) bool {
 
 type Git struct {
 	*gitlib.Git
-	shard string
+	shard    string
+	codec    codec.Codec
+	wrappers []func(zoom.Store) zoom.Store
+	subs     *subscriptions
+}
+
+// Wrap adds wrappers that are put around the store of each transaction, e.g. (*zoom.Indexes).Wrap
+// the last added wrapper is the outermost
+func (g *Git) Wrap(wrappers ...func(zoom.Store) zoom.Store) {
+	g.wrappers = append(g.wrappers, wrappers...)
 }
 
+// Open opens the git repository inside baseDir for the given shard, initializing it if needed.
+// node and edge files are written with the JSON codec.
 func Open(baseDir string, shard string) (g Git, err error) {
+	return OpenWithCodec(baseDir, shard, codec.JSON)
+}
+
+// OpenWithCodec is like Open but writes node and edge files with the given codec.
+// Files are always read with the codec that wrote them, so the codec of a repository
+// may be changed at any time (see Recode).
+func OpenWithCodec(baseDir string, shard string, c codec.Codec) (g Git, err error) {
 	// fmt.Println("opening")
 
 	//gitBase := filepath.Join(baseDir, ".git")
@@ -97,20 +116,92 @@ func Open(baseDir string, shard string) (g Git, err error) {
 			return
 		}
 	}
-	g = Git{Git: git, shard: shard}
+	g = Git{Git: git, shard: shard, codec: c, subs: newSubscriptions()}
 	return
 }
 
+// Transaction runs the given action via zoom.NewTransaction and notifies the subscribers (see Subscribe),
+// if a commit has been made. Errors of the notification are passed to the subscribers, they are not returned,
+// since the commit has been made.
 func (g *Git) Transaction(msg zoom.CommitMessage, action func(zoom.Transaction) error) (err error) {
-	return g.Git.Transaction(func(tx *gitlib.Transaction) error {
-		var store zoom.Store = &Store{tx, g.shard}
-		return zoom.NewTransaction(store, msg, action)
+	var parent, commit string
+	err = g.Git.Transaction(func(tx *gitlib.Transaction) error {
+		var store zoom.Store = g.newStore(tx)
+		for _, wrap := range g.wrappers {
+			store = wrap(store)
+		}
+
+		if parent, err = tx.ShowHeadsRef("master"); err != nil {
+			return err
+		}
+		if err := zoom.NewTransaction(store, msg, action); err != nil {
+			return err
+		}
+		commit, err = tx.ShowHeadsRef("master")
+		return err
+	})
+
+	if err != nil || commit == parent {
+		return err
+	}
+	g.notify(CommitInfo{Commit: commit, Parent: parent, Message: msg})
+	return nil
+}
+
+func (g *Git) newStore(tx *gitlib.Transaction) *Store {
+	return &Store{Transaction: tx, shard: g.shard, codec: g.codec}
+}
+
+// unwrapStore returns the *Store inside the wrappers of tr (see Wrap)
+func unwrapStore(tr zoom.Transaction) (*Store, error) {
+	var st interface{} = tr
+	for {
+		if s, ok := st.(*Store); ok {
+			return s, nil
+		}
+		u, ok := st.(zoom.Unwrapper)
+		if !ok {
+			return nil, fmt.Errorf("transaction %T does not wrap a *gitstore.Store", tr)
+		}
+		st = u.Unwrap()
+	}
+}
+
+// Recode rewrites all node and edge files of the shard that have not been written by the codec of g,
+// so that a repository can be migrated from one codec to another.
+func (g *Git) Recode(msg zoom.CommitMessage) error {
+	return g.Transaction(msg, func(tr zoom.Transaction) error {
+		s, err := unwrapStore(tr)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range []string{"node/%s/*", "refs/*/%s/*", "backrefs/*/%s/*"} {
+			files, err := s.LsFiles(fmt.Sprintf(pattern, s.shard))
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
+				if err := s.recode(file); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
 	})
 }
 
 type Store struct {
 	*gitlib.Transaction
 	shard string
+	codec codec.Codec
+
+	// blobs are written to stageDir and moved to the blob dir on Commit, so that they can be rolled back
+	stageDir    string
+	stagedBlobs map[string]map[string]bool // uuid => blobname
+
+	// uuids of removed nodes, their blob dirs are removed from the disk on Commit
+	removedBlobs map[string]bool
 }
 
 // map relname => nodeUuid, only the texts that have a key set are going to be changed
@@ -175,34 +266,53 @@ func (s *Store) saveBlobToFile(path string, blob io.Reader) error {
 	return nil
 }
 
-/*
-// map poolname => []nodeUuid, only the blobs that have a key set are going to be changed
+// map blobname => content, only the blobs that have a key set are going to be changed
+// blobs are staged outside the repo and moved to the blob dir on Commit
 func (s *Store) SaveNodeBlobs(uuid string, blobs map[string]io.Reader) error {
+	if s.stageDir == "" {
+		base := filepath.Join(s.Git.Dir, "../blobstage")
+		if err := os.MkdirAll(base, 0755); err != nil {
+			return err
+		}
+		dir, err := ioutil.TempDir(base, s.shard+"_")
+		if err != nil {
+			return err
+		}
+		s.stageDir = dir
+	}
+
+	if s.stagedBlobs == nil {
+		s.stagedBlobs = map[string]map[string]bool{}
+	}
+	if s.stagedBlobs[uuid] == nil {
+		s.stagedBlobs[uuid] = map[string]bool{}
+	}
 
 	for blobPath, blob := range blobs {
-		path := filepath.Join(s.Git.Dir, s.BlobPath(uuid, blobPath))
-		if err := s.saveBlobToFile(path, blob); err != nil {
+		if err := s.saveBlobToFile(s.stagedBlobPath(uuid, blobPath), blob); err != nil {
 			return err
 		}
+		s.stagedBlobs[uuid][blobPath] = true
 	}
 	return nil
 }
-*/
 
-/*
-func (s *Store) SaveIndex(indexpath string, shard string, rd io.Reader) error {
-	path := filepath.Join(s.Git.Dir, s.indexPath(shard, indexpath))
+// SaveIndex saves the index file outside of the repo, it is not part of the transaction
+func (s *Store) SaveIndex(indexpath string, rd io.Reader) error {
+	path := filepath.Join(s.Git.Dir, s.indexPath(indexpath))
 	return s.saveBlobToFile(path, rd)
 }
-*/
-
-/*
 
-*/
+// RenameIndex renames the index file from to the index file to, it is not part of the transaction
+func (s *Store) RenameIndex(from, to string) error {
+	toPath := filepath.Join(s.Git.Dir, s.indexPath(to))
+	if err := os.MkdirAll(filepath.Dir(toPath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(s.Git.Dir, s.indexPath(from)), toPath)
+}
 
-/*
-func (s *Store) callwithBlob(uuid string, blobPath string, fn func(string, io.Reader) error) error {
-	path := filepath.Join(s.Git.Dir, s.blobPath(uuid, blobPath))
+func (s *Store) callwithBlob(path string, blobPath string, fn func(string, io.Reader) error) error {
 	if FileExists(path) {
 		file, err := os.Open(path)
 		if err != nil {
@@ -214,11 +324,10 @@ func (s *Store) callwithBlob(uuid string, blobPath string, fn func(string, io.Re
 	}
 	return nil
 }
-*/
 
-/*
-func (s *Store) GetIndex(indexpath string, shard string, fn func(io.Reader) error) error {
-	path := filepath.Join(s.Git.Dir, s.indexPath(shard, indexpath))
+// GetIndex calls fn with the content of the index file, if it exists
+func (s *Store) GetIndex(indexpath string, fn func(io.Reader) error) error {
+	path := filepath.Join(s.Git.Dir, s.indexPath(indexpath))
 	if FileExists(path) {
 		file, err := os.Open(path)
 		if err != nil {
@@ -230,21 +339,26 @@ func (s *Store) GetIndex(indexpath string, shard string, fn func(io.Reader) erro
 	}
 	return nil
 }
-*/
 
-// GetNodeBlobs calls fn for each existing blob in requestedBlobs
-/*
+// GetNodeBlobs calls fn for each existing blob in requestedBlobs, staged blobs are preferred
 func (s *Store) GetNodeBlobs(uuid string, requestedBlobs []string, fn func(string, io.Reader) error) error {
 	for _, blob := range requestedBlobs {
-		err := s.callwithBlob(uuid, blob, fn)
+		var path string
+		switch {
+		case s.stagedBlobs[uuid][blob]:
+			path = s.stagedBlobPath(uuid, blob)
+		case s.removedBlobs[uuid]:
+			continue
+		default:
+			path = filepath.Join(s.Git.Dir, s.BlobPath(uuid, blob))
+		}
 
-		if err != nil {
+		if err := s.callwithBlob(path, blob, fn); err != nil {
 			return err
 		}
 	}
 	return nil
 }
-*/
 
 func (s *Store) GetNodeTexts(uuid string, requestedTexts []string) (texts map[string]string, err error) {
 	texts = map[string]string{}
@@ -258,7 +372,7 @@ func (s *Store) GetNodeTexts(uuid string, requestedTexts []string) (texts map[st
 		// fmt.Printf("file %s is known: %v\n", text, known)
 		if known {
 			var buf bytes.Buffer
-			err = s.ReadCatHeadFile(s.textPath(uuid, text), &buf)
+			err = s.readFile(s.textPath(uuid, text), &buf)
 			if err != nil {
 				return
 			}
@@ -277,7 +391,7 @@ func (s *Store) SaveEdges(category, uuid string, edges map[string]string) error
 	return s.save(path, !known, edges)
 }
 
-// RemoveEdges also removes the properties node of an edge
+// RemoveEdges also removes the properties node of an edge and the edges from the incoming edges of their targets in the shard
 // Is the edges file is already removed, no error should be returned
 func (s *Store) RemoveEdges(category, uuid string) error {
 	edges, err := s.GetEdges(category, uuid)
@@ -285,16 +399,74 @@ func (s *Store) RemoveEdges(category, uuid string) error {
 		return err
 	}
 
-	for _, propID := range edges {
+	for _, val := range edges {
+		propID, _, _, err := zoom.ParseEdgeValue(val)
+		if err != nil {
+			return err
+		}
+		if propID == "" {
+			continue
+		}
 		if err := s.RemoveNode(propID); err != nil {
 			return err
 		}
 	}
 
+	if err := s.removeIncoming(category, uuid, edges); err != nil {
+		return err
+	}
+
 	path := s.edgePath(category, uuid)
 	return s.RemoveIndex(path)
 }
 
+// removeIncoming removes the edges of the node from the incoming edges of their targets in the shard.
+// the incoming edges of targets in other shards are part of their stores (see zoom.Store.RemoveEdges)
+func (s *Store) removeIncoming(category, uuid string, edges map[string]string) error {
+	for key := range edges {
+		shard, to, id, err := zoom.SplitEdgeKey(key)
+		if err != nil {
+			return err
+		}
+		if shard != s.shard {
+			continue
+		}
+
+		incoming, err := s.GetIncomingEdges(category, to)
+		if err != nil {
+			return err
+		}
+		delete(incoming, zoom.EdgeKey(s.shard+"-"+uuid, id))
+		if len(incoming) == 0 {
+			err = s.RemoveIncomingEdges(category, to)
+		} else {
+			err = s.SaveIncomingEdges(category, to, incoming)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAllIncoming removes all edges of the node from the incoming edges of their targets in the shard
+func (s *Store) removeAllIncoming(uuid string) error {
+	categories, err := s.GetEdgeCategories(uuid)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		edges, err := s.GetEdges(category, uuid)
+		if err != nil {
+			return err
+		}
+		if err := s.removeIncoming(category, uuid, edges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // if there is no edge file for the given category, no error is returned, but empty  edges map
 func (s *Store) GetEdges(category, uuid string) (edges map[string]string, err error) {
 	path := s.edgePath(category, uuid)
@@ -312,11 +484,63 @@ func (s *Store) GetEdges(category, uuid string) (edges map[string]string, err er
 	return edges, err
 }
 
+// GetEdgeCategories returns the sorted categories of the edge files of the node
+func (s *Store) GetEdgeCategories(uuid string) (categories []string, err error) {
+	suffix := fmt.Sprintf("/%s/%s/%s", s.shard, uuid[:2], uuid[2:])
+	files, err := s.LsFiles("refs/*" + suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	categories = []string{}
+	for _, file := range files {
+		categories = append(categories, strings.TrimSuffix(strings.TrimPrefix(file, "refs/"), suffix))
+	}
+	sort.Strings(categories)
+	return
+}
+
+func (s *Store) SaveIncomingEdges(category, uuid string, edges map[string]string) error {
+	path := s.incomingEdgePath(category, uuid)
+	known, err := s.IsFileKnown(path)
+	if err != nil {
+		return err
+	}
+	return s.save(path, !known, edges)
+}
+
+// RemoveIncomingEdges does not remove the property nodes of the edges, since they belong to the shard of the edges
+// Is the incoming edges file is already removed, no error should be returned
+func (s *Store) RemoveIncomingEdges(category, uuid string) error {
+	return s.RemoveIndex(s.incomingEdgePath(category, uuid))
+}
+
+// if there is no incoming edge file for the given category, no error is returned, but empty  edges map
+func (s *Store) GetIncomingEdges(category, uuid string) (edges map[string]string, err error) {
+	path := s.incomingEdgePath(category, uuid)
+	edges = map[string]string{}
+
+	known, err := s.IsFileKnown(path)
+	if err != nil {
+		return edges, err
+	}
+
+	if !known {
+		return edges, nil
+	}
+	err = s.load(path, &edges)
+	return edges, err
+}
+
 func (s *Store) edgePath(category string, uuid string) string {
 	//return fmt.Sprintf("node/props/%s/%s", uuid[:2], uuid[2:])
 	return fmt.Sprintf("refs/%s/%s/%s/%s", category, s.shard, uuid[:2], uuid[2:])
 }
 
+func (s *Store) incomingEdgePath(category string, uuid string) string {
+	return fmt.Sprintf("backrefs/%s/%s/%s/%s", category, s.shard, uuid[:2], uuid[2:])
+}
+
 func (s *Store) propPath(uuid string) string {
 	//return fmt.Sprintf("node/props/%s/%s", uuid[:2], uuid[2:])
 	return fmt.Sprintf("node/%s/%s/%s", s.shard, uuid[:2], uuid[2:])
@@ -331,6 +555,52 @@ func (s *Store) BlobPath(uuid string, blobpath string) string {
 	return fmt.Sprintf("../blob/%s/%s/%s/%s", s.shard, uuid[:2], uuid[2:], blobpath)
 }
 
+func (s *Store) indexPath(indexpath string) string {
+	return fmt.Sprintf("../index/%s/%s", s.shard, indexpath)
+}
+
+func (s *Store) blobDir(uuid string) string {
+	return filepath.Join(s.Git.Dir, fmt.Sprintf("../blob/%s/%s/%s", s.shard, uuid[:2], uuid[2:]))
+}
+
+func (s *Store) stagedBlobPath(uuid string, blobpath string) string {
+	return filepath.Join(s.stageDir, uuid[:2], uuid[2:], blobpath)
+}
+
+// commitBlobs removes the blob dirs of the removed nodes and moves the staged blobs to the blob dir
+func (s *Store) commitBlobs() error {
+	for uuid := range s.removedBlobs {
+		if err := os.RemoveAll(s.blobDir(uuid)); err != nil {
+			return err
+		}
+	}
+
+	for uuid, blobs := range s.stagedBlobs {
+		for blob := range blobs {
+			path := filepath.Join(s.Git.Dir, s.BlobPath(uuid, blob))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(s.stagedBlobPath(uuid, blob), path); err != nil {
+				return err
+			}
+		}
+	}
+	return s.discardBlobs()
+}
+
+// discardBlobs throws away the staged blobs and the removals of blob dirs
+func (s *Store) discardBlobs() error {
+	s.stagedBlobs = nil
+	s.removedBlobs = nil
+	if s.stageDir == "" {
+		return nil
+	}
+	dir := s.stageDir
+	s.stageDir = ""
+	return os.RemoveAll(dir)
+}
+
 func (g *Store) Commit(msg zoom.CommitMessage) error {
 	// fmt.Println("commit from store " + comment)
 	treeSha, err := g.Transaction.WriteTree()
@@ -346,21 +616,24 @@ func (g *Store) Commit(msg zoom.CommitMessage) error {
 	}
 
 	var commitSha string
-	commitSha, err = g.CommitTree(treeSha, parent, strings.NewReader(msg.String()))
+	commitSha, err = g.CommitTree(treeSha, parent, strings.NewReader(msg.Format()))
 
 	if err != nil {
 		return err
 	}
 
-	return g.UpdateHeadsRef("master", commitSha)
+	err = g.UpdateHeadsRef("master", commitSha)
+	if err != nil {
+		return err
+	}
+
+	return g.commitBlobs()
 }
 
 func (g *Store) save(path string, isNew bool, data interface{}) error {
 	// fmt.Printf("storing: %#v in %#v\n", data, path)
 	var buf bytes.Buffer
-	// enc := msgpack.NewEncoder(&buf)
-	enc := json.NewEncoder(&buf)
-	err := enc.Encode(data)
+	err := codec.Write(&buf, g.codec, data)
 	if err != nil {
 		return err
 	}
@@ -386,16 +659,35 @@ func (g *Store) save(path string, isNew bool, data interface{}) error {
 func (g *Store) load(path string, data interface{}) error {
 	// fmt.Println("loading from ", path)
 	var buf bytes.Buffer
-	err := g.Transaction.ReadCatHeadFile(path, &buf)
+	err := g.readFile(path, &buf)
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
 
 	// fmt.Println("reading", buf.String())
-	//dec := msgpack.NewDecoder(&buf)
-	dec := json.NewDecoder(&buf)
-	return dec.Decode(data)
+	_, err = codec.Read(&buf, data)
+	return err
+}
+
+// recode rewrites the file with the codec of the store, if it has been written with another codec
+func (g *Store) recode(path string) error {
+	var buf bytes.Buffer
+	err := g.readFile(path, &buf)
+	if err != nil {
+		return err
+	}
+
+	var data interface{}
+	c, err := codec.Read(&buf, &data)
+	if err != nil {
+		return fmt.Errorf("can't read %s: %s", path, err)
+	}
+
+	if c.Name() == g.codec.Name() {
+		return nil
+	}
+	return g.save(path, false, data)
 }
 
 // only the props that have a key set are going to be changed
@@ -409,8 +701,7 @@ func (g *Store) SaveNodeProperties(uuid string, props map[string]interface{}) er
 	}
 
 	if known {
-		orig := map[string]interface{}{}
-		err := g.load(path, &orig)
+		orig, err := g.loadProps(path)
 		if err != nil {
 			return err
 		}
@@ -425,13 +716,36 @@ func (g *Store) SaveNodeProperties(uuid string, props map[string]interface{}) er
 		props = orig
 	}
 
-	return g.save(path, !known, props)
+	return g.saveProps(path, !known, props)
+}
+
+// saveProps saves the properties with their types
+func (g *Store) saveProps(path string, isNew bool, props map[string]interface{}) error {
+	data, err := codec.EncodeProperties(props)
+	if err != nil {
+		return err
+	}
+	return g.save(path, isNew, data)
+}
+
+// loadProps loads the properties that have been saved via saveProps
+// property files without types are also supported
+func (g *Store) loadProps(path string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := g.load(path, &data)
+	if err != nil {
+		return nil, err
+	}
+	return codec.DecodeProperties(data)
 }
 
 // TODO Rollback any actions that have been taken since the last commit
 // stage should be cleared and any newly added data should be removed
 // maybe a cleanup command should remove the orphaned sha1s (git gc maybe??)
 func (g *Store) Rollback() error {
+	if err := g.discardBlobs(); err != nil {
+		return err
+	}
 	return g.ResetToHeadAll()
 }
 
@@ -441,26 +755,44 @@ func (g *Store) Rollback() error {
 // ls refs/*/shard/uuid[:2], uuid[2:]
 // return fmt.Sprintf("refs/%s/%s/%s/%s", category, shard, uuid[:2], uuid[2:])
 // if any file does not exist, no error should be returned
+// the edges of the node are removed from the incoming edges of their targets in the shard
 func (g *Store) RemoveNode(uuid string) error {
 	// fmt.Printf("trying to remove node: uuid %#v shard %#v\n", uuid, shard)
 	// fmt.Println("proppath is ", g.propPath(uuid))
 	paths := []string{
 		fmt.Sprintf("text/%s/%s/%s", g.shard, uuid[:2], uuid[2:]),
-		fmt.Sprintf("blob/%s/%s/%s", g.shard, uuid[:2], uuid[2:]),
 	}
 
-	files, err := g.LsFiles(fmt.Sprintf("refs/*/%s/%s/%s", g.shard, uuid[:2], uuid[2:]))
-	if err != nil {
-		// fmt.Println("error from ls files")
-		return err
+	// the blobs are outside the repo, so we remove them not before the commit
+	if g.removedBlobs == nil {
+		g.removedBlobs = map[string]bool{}
+	}
+	g.removedBlobs[uuid] = true
+	if g.stagedBlobs[uuid] != nil {
+		delete(g.stagedBlobs, uuid)
+		if err := os.RemoveAll(filepath.Join(g.stageDir, uuid[:2], uuid[2:])); err != nil {
+			return err
+		}
 	}
 
-	for _, file := range files {
+	if err := g.removeAllIncoming(uuid); err != nil {
+		return err
+	}
 
-		err := g.Transaction.RemoveIndex(file)
+	for _, dir := range []string{"refs", "backrefs"} {
+		files, err := g.LsFiles(fmt.Sprintf("%s/*/%s/%s/%s", dir, g.shard, uuid[:2], uuid[2:]))
 		if err != nil {
+			// fmt.Println("error from ls files")
 			return err
 		}
+
+		for _, file := range files {
+
+			err := g.Transaction.RemoveIndex(file)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, path := range paths {
@@ -486,13 +818,47 @@ func (g *Store) RemoveNode(uuid string) error {
 		}
 	}
 
-	err = g.Transaction.RemoveIndex(g.propPath(uuid))
+	err := g.Transaction.RemoveIndex(g.propPath(uuid))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// ScanNodes lists the properties files of the shard and calls fn for the uuids with the given prefix in order
+func (g *Store) ScanNodes(prefix string, fn func(uuid string) error) error {
+	dir := fmt.Sprintf("node/%s/", g.shard)
+	pattern := dir + "*"
+	// a prefix with glob characters would match other files, then all files are listed and filtered below
+	if len(prefix) >= 2 && !strings.ContainsAny(prefix, `*?[]\`) {
+		pattern = dir + prefix[:2] + "/" + prefix[2:] + "*"
+	}
+
+	files, err := g.LsFiles(pattern)
+	if err != nil {
+		return err
+	}
+
+	uuids := make([]string, 0, len(files))
+	for _, file := range files {
+		uuid := strings.Replace(strings.TrimPrefix(file, dir), "/", "", 1)
+		if strings.HasPrefix(uuid, prefix) {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+
+	for _, uuid := range uuids {
+		if err := fn(uuid); err != nil {
+			if err == zoom.StopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // only the properties that exist make it into the returned map
 // it is no error if a requested property does not exist for a node
 // the caller has to check the returned map against the requested props if
@@ -500,8 +866,7 @@ func (g *Store) RemoveNode(uuid string) error {
 // if the node properties file is not there no error should be returned
 func (g *Store) GetNodeProperties(uuid string, requestedProps []string) (props map[string]interface{}, err error) {
 	path := g.propPath(uuid)
-	orig := map[string]interface{}{}
-	err = g.load(path, &orig)
+	orig, err := g.loadProps(path)
 	if err != nil {
 		return nil, err
 	}
@@ -1,11 +1,18 @@
 package gitstore
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/metakeule/gitlib"
 	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/codec"
+	"github.com/metakeule/zoom/storetest"
 )
 
 func withGit(fn func(*Git)) error {
@@ -17,16 +24,45 @@ func withGit(fn func(*Git)) error {
 
 	defer os.RemoveAll(dir)
 
-	git, err := Open(dir)
+	git, err := Open(dir, "shard1")
 
 	if err != nil {
 		return err
 	}
 
-	fn(git)
+	fn(&git)
 	return nil
 }
 
+func TestStoreSuite(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	// each test runs inside the git transaction its store belongs to
+	for i, test := range storetest.Tests {
+		git, err := Open(filepath.Join(dir, strconv.Itoa(i)), "shard1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fn := test.Fn
+		t.Run(test.Name, func(t *testing.T) {
+			err := git.Git.Transaction(func(tx *gitlib.Transaction) error {
+				fn(t, git.newStore(tx))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func TestNodes(t *testing.T) {
 	tests := [...]map[string]interface{}{
 		{
@@ -45,30 +81,32 @@ func TestNodes(t *testing.T) {
 
 	err := withGit(func(git *Git) {
 		for i, test := range tests {
-			var n = zoom.NewNode("shard1")
-			n.SetFloat("Age", test["Age"].(float64))
-			n.SetString("FirstName", test["FirstName"].(string))
-			n.SetString("LastName", test["LastName"].(string))
-			// n.Set(test)
-			_, err := git.Transaction("save test", n.Save)
+			err := git.Transaction(zoom.CommitMessage{Command: "save test"}, func(tr zoom.Transaction) error {
+				var n = zoom.NewNode(tr, "")
+				n.SetFloat("Age", test["Age"].(float64))
+				n.SetString("FirstName", test["FirstName"].(string))
+				n.SetString("LastName", test["LastName"].(string))
+				testsIds[i] = n.ID()
+				return n.Save()
+			})
 
 			if err != nil {
 				t.Fatal(err)
 			}
-			testsIds[i] = n.ID()
 		}
 
 		for i, id := range testsIds {
-			var n = zoom.NewNode(id)
+			var n *zoom.Node
 
-			_, err := git.Transaction("get", func(st zoom.Store) error {
+			err := git.Transaction(zoom.CommitMessage{Command: "get"}, func(tr zoom.Transaction) error {
+				n = zoom.NewNode(tr, id)
 				query := []string{}
 
 				for k := range tests[i] {
 					query = append(query, k)
 				}
 
-				return n.LoadProperties(st, query)
+				return n.LoadProperties(query)
 			})
 
 			if err != nil {
@@ -197,3 +235,194 @@ func TestRelation(t *testing.T) {
 
 }
 */
+
+func TestRecode(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id string
+	err = git.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetInt("Age", 44)
+		id = n.ID()
+		if err := n.Save(); err != nil {
+			return err
+		}
+		return n.NewEdge("knows", zoom.NewNode(tr, ""), nil)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	git, err = OpenWithCodec(dir, "shard1", codec.MsgPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the wrappers are put around the store of the recoding transaction
+	git.Wrap(zoom.NewIndexes(zoom.NewIndex("age", "Age", zoom.StringWidth, zoom.UUIDWidth, -1)).Wrap)
+
+	if err := git.Recode(zoom.CommitMessage{Command: "recode"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{Command: "check"}, func(tr zoom.Transaction) error {
+		st, err := unwrapStore(tr)
+		if err != nil {
+			return err
+		}
+		for _, path := range []string{st.propPath(id), st.edgePath("knows", id)} {
+			var buf bytes.Buffer
+			if err := st.readFile(path, &buf); err != nil {
+				return err
+			}
+
+			if !strings.HasPrefix(buf.String(), "#msgpack\n") {
+				t.Errorf("%s has not been recoded: %#v", path, buf.String())
+			}
+		}
+
+		n := zoom.NewNode(tr, id)
+		if err := n.LoadProperties([]string{"Age"}); err != nil {
+			return err
+		}
+
+		if age := n.GetInt("Age"); age != 44 {
+			t.Errorf("Age = %d, expected 44", age)
+		}
+
+		return zoom.ErrNoCommit
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLegacyUntypedFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := "0a8f2ad5-9e02-4c3a-8f0e-8f1a7c2d6b01"
+
+	// a properties file written before the properties had types and the files a codec header
+	err = git.Transaction(zoom.CommitMessage{Command: "legacy"}, func(tr zoom.Transaction) error {
+		st := tr.(*Store)
+		sha1, err := st.WriteHashObject(strings.NewReader(`{"Age":44,"Score":1.5}`))
+		if err != nil {
+			return err
+		}
+		return st.AddIndexCache(sha1, st.propPath(id))
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{Command: "check"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		if err := n.LoadProperties([]string{"Age", "Score"}); err != nil {
+			return err
+		}
+
+		if age := n.GetInt("Age"); age != 44 {
+			t.Errorf("Age = %d, expected 44", age)
+		}
+
+		if score := n.GetFloat("Score"); score != 1.5 {
+			t.Errorf("Score = %v, expected 1.5", score)
+		}
+
+		return zoom.ErrNoCommit
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchReadsLikeSingleReads(t *testing.T) {
+	err := withGit(func(git *Git) {
+		var id string
+		err := git.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+			n := zoom.NewNode(tr, "")
+			id = n.ID()
+			n.SetString("Name", "Donald")
+			n.SetText("Bio", "committed")
+			return n.Save()
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the batch must see the changes of the transaction like the single reads
+		err = git.Transaction(zoom.CommitMessage{Command: "check"}, func(tr zoom.Transaction) error {
+			n := zoom.NewNode(tr, id)
+			n.SetString("Name", "Daisy")
+			n.SetText("Bio", "staged")
+			if err := n.Save(); err != nil {
+				return err
+			}
+
+			st, err := unwrapStore(tr)
+			if err != nil {
+				return err
+			}
+
+			single, err := st.GetNodeProperties(id, []string{"Name"})
+			if err != nil {
+				return err
+			}
+			batch, err := st.GetNodesProperties([]string{id}, []string{"Name"})
+			if err != nil {
+				return err
+			}
+			if single["Name"] != "Daisy" || batch[id]["Name"] != "Daisy" {
+				t.Errorf("Name = %#v (single), %#v (batch), expected Daisy", single["Name"], batch[id]["Name"])
+			}
+
+			singleTexts, err := st.GetNodeTexts(id, []string{"Bio"})
+			if err != nil {
+				return err
+			}
+			batchTexts, err := st.GetNodesTexts([]string{id}, []string{"Bio"})
+			if err != nil {
+				return err
+			}
+			if singleTexts["Bio"] != "staged" || batchTexts[id]["Bio"] != "staged" {
+				t.Errorf("Bio = %#v (single), %#v (batch), expected staged", singleTexts["Bio"], batchTexts[id]["Bio"])
+			}
+
+			return zoom.ErrNoCommit
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
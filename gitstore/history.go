@@ -0,0 +1,291 @@
+package gitstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/codec"
+)
+
+/*
+	history
+
+	Every commit of a Store is a git commit on the master branch, so the state of the shard at each commit
+	can be read again. A Snapshot is a read-only zoom.Transaction bound to one commit. It reads the node,
+	text and edge files of that commit; blobs and indexes are saved outside of the repository and have no history.
+*/
+
+// ErrReadOnly is returned by the methods of a Snapshot that would change data
+var ErrReadOnly = errors.New("gitstore: snapshot is read-only")
+
+// ErrNoBlobHistory is returned by Snapshot.GetNodeBlobs, since blobs are not versioned
+var ErrNoBlobHistory = errors.New("gitstore: blobs have no history")
+
+// run runs git with the given arguments on the repository and returns the output
+func (g *Git) run(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"--git-dir=" + g.Dir}, args...)...)
+	cmd.Dir = filepath.Dir(g.Dir)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %s %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// git is like run but returns the output as string without the trailing newlines
+func (g *Git) git(args ...string) (string, error) {
+	out, err := g.run(args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// resolve returns the sha of the commit for the given revision (sha, tag, branch or any other git revision)
+func (g *Git) resolve(rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("invalid revision %#v", rev)
+	}
+	return g.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+}
+
+// At returns a read-only transaction for the state of the shard at the given revision,
+// which may be a commit sha, a tag or any other git revision
+func (g *Git) At(rev string) (*Snapshot, error) {
+	sha, err := g.resolve(rev)
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %#v", rev)
+	}
+	return g.snapshot(sha), nil
+}
+
+// AtTime returns a read-only transaction for the state of the shard at the given time,
+// i.e. at the last commit that has been made not after t
+func (g *Git) AtTime(t time.Time) (*Snapshot, error) {
+	sha, err := g.git("rev-list", "-1", fmt.Sprintf("--before=@%d", t.Unix()), "refs/heads/master")
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		return nil, fmt.Errorf("no commit before %s", t.Format(time.RFC3339))
+	}
+	return g.snapshot(sha), nil
+}
+
+func (g *Git) snapshot(sha string) *Snapshot {
+	return &Snapshot{git: g, commit: sha, paths: &Store{shard: g.shard, codec: g.codec}}
+}
+
+// Tag tags the current commit with the given name, so that it can be passed to At
+func (g *Git) Tag(name string) error {
+	_, err := g.git("tag", name, "refs/heads/master")
+	return err
+}
+
+// Snapshot is a read-only zoom.Transaction for the state of a shard at a commit (see Git.At)
+type Snapshot struct {
+	git    *Git
+	commit string
+	paths  *Store // only used for the paths of the files
+}
+
+var _ zoom.Transaction = &Snapshot{}
+
+// Commit returns the sha of the commit of the snapshot
+func (s *Snapshot) Commit() string {
+	return s.commit
+}
+
+func (s *Snapshot) Shard() string {
+	return s.git.shard
+}
+
+// lsFiles returns the sorted files of the commit inside the given dir
+func (s *Snapshot) lsFiles(dir string) ([]string, error) {
+	out, err := s.git.git("ls-tree", "-r", "--name-only", s.commit, "--", dir)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	files := strings.Split(out, "\n")
+	sort.Strings(files)
+	return files, nil
+}
+
+// emptyTree is the sha of the tree without files
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// lsPattern returns the sorted files of the commit that match the given pathspec pattern.
+// ls-tree does not support patterns, so the files are listed as difference to the empty tree.
+func (s *Snapshot) lsPattern(pattern string) ([]string, error) {
+	out, err := s.git.run("diff-tree", "-r", "-z", "--no-renames", "--name-only", emptyTree, s.commit, "--", pattern)
+	if err != nil {
+		return nil, err
+	}
+	files := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if len(files) == 1 && files[0] == "" {
+		return nil, nil
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *Snapshot) isFileKnown(path string) (bool, error) {
+	out, err := s.git.git("ls-tree", "--name-only", s.commit, "--", path)
+	return out == path, err
+}
+
+func (s *Snapshot) read(path string) ([]byte, error) {
+	out, err := s.git.run("cat-file", "blob", s.commit+":"+path)
+	if err != nil {
+		return nil, fmt.Errorf("file %#v does not exist in commit %s", path, s.commit)
+	}
+	return out, nil
+}
+
+func (s *Snapshot) load(path string, data interface{}) error {
+	b, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	_, err = codec.Read(bytes.NewReader(b), data)
+	return err
+}
+
+// loadEdges returns an empty map, if the edges file does not exist
+func (s *Snapshot) loadEdges(path string) (map[string]string, error) {
+	edges := map[string]string{}
+	known, err := s.isFileKnown(path)
+	if err != nil || !known {
+		return edges, err
+	}
+	err = s.load(path, &edges)
+	return edges, err
+}
+
+func (s *Snapshot) GetNodeProperties(uuid string, requestedProps []string) (props map[string]interface{}, err error) {
+	data := map[string]interface{}{}
+	if err := s.load(s.paths.propPath(uuid), &data); err != nil {
+		return nil, err
+	}
+	orig, err := codec.DecodeProperties(data)
+	if err != nil {
+		return nil, err
+	}
+
+	props = map[string]interface{}{}
+	for _, req := range requestedProps {
+		if v, has := orig[req]; has {
+			props[req] = v
+		}
+	}
+	return props, nil
+}
+
+func (s *Snapshot) GetNodeTexts(uuid string, requestedTexts []string) (texts map[string]string, err error) {
+	texts = map[string]string{}
+	for _, text := range requestedTexts {
+		path := s.paths.textPath(uuid, text)
+		known, err := s.isFileKnown(path)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			continue
+		}
+		b, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		texts[text] = string(b)
+	}
+	return texts, nil
+}
+
+// GetNodeBlobs returns ErrNoBlobHistory, since the blobs are saved outside of the repository
+func (s *Snapshot) GetNodeBlobs(uuid string, requestedBlobs []string, fn func(string, io.Reader) error) error {
+	return ErrNoBlobHistory
+}
+
+func (s *Snapshot) GetEdges(category, uuid string) (edges map[string]string, err error) {
+	return s.loadEdges(s.paths.edgePath(category, uuid))
+}
+
+func (s *Snapshot) GetIncomingEdges(category, uuid string) (edges map[string]string, err error) {
+	return s.loadEdges(s.paths.incomingEdgePath(category, uuid))
+}
+
+func (s *Snapshot) GetEdgeCategories(uuid string) (categories []string, err error) {
+	suffix := fmt.Sprintf("/%s/%s/%s", s.git.shard, uuid[:2], uuid[2:])
+	files, err := s.lsPattern("refs/*" + suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	categories = []string{}
+	for _, file := range files {
+		categories = append(categories, strings.TrimSuffix(strings.TrimPrefix(file, "refs/"), suffix))
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+func (s *Snapshot) ScanNodes(prefix string, fn func(uuid string) error) error {
+	dir := fmt.Sprintf("node/%s/", s.git.shard)
+	files, err := s.lsFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		uuid := strings.Replace(strings.TrimPrefix(file, dir), "/", "", 1)
+		if !strings.HasPrefix(uuid, prefix) {
+			continue
+		}
+		if err := fn(uuid); err != nil {
+			if err == zoom.StopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) SaveNodeProperties(uuid string, props map[string]interface{}) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) SaveNodeTexts(uuid string, texts map[string]string) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) SaveNodeBlobs(uuid string, blobs map[string]io.Reader) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) SaveEdges(category, fromUUID string, edges map[string]string) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) RemoveEdges(category, fromUUID string) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) SaveIncomingEdges(category, toUUID string, edges map[string]string) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) RemoveIncomingEdges(category, toUUID string) error {
+	return ErrReadOnly
+}
+
+func (s *Snapshot) RemoveNode(uuid string) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,147 @@
+package gitstore
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestAt(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id, friend string
+	err = git.Transaction(zoom.CommitMessage{Command: "create"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("Name", "Donald")
+		n.SetText("Bio", "a duck")
+		id = n.ID()
+		if err := n.Save(); err != nil {
+			return err
+		}
+		f := zoom.NewNode(tr, "")
+		friend = f.ID()
+		if err := f.Save(); err != nil {
+			return err
+		}
+		return n.NewEdge("knows", f, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := git.Tag("v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := git.At("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = git.Transaction(zoom.CommitMessage{Command: "update"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.SetString("Name", "Donald Duck")
+		n.SetText("Bio", "a famous duck")
+		if err := n.Save(); err != nil {
+			return err
+		}
+		return n.RemoveEdge("knows", zoom.NewNode(tr, friend))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rev, expected := range map[string]string{"v1": "Donald", first.Commit(): "Donald", "master": "Donald Duck"} {
+		snap, err := git.At(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n := zoom.NewNode(snap, id)
+		if err := n.LoadProperties([]string{"Name"}); err != nil {
+			t.Fatal(err)
+		}
+		if name := n.GetString("Name"); name != expected {
+			t.Errorf("Name at %s = %#v, expected %#v", rev, name, expected)
+		}
+	}
+
+	n := zoom.NewNode(first, id)
+	if err := n.LoadTexts([]string{"Bio"}); err != nil {
+		t.Fatal(err)
+	}
+	if bio := n.GetText("Bio"); bio != "a duck" {
+		t.Errorf("Bio at v1 = %#v, expected %#v", bio, "a duck")
+	}
+
+	edges, err := n.GetEdges(first, "knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 1 || edges[0].To.ID() != friend {
+		t.Errorf("edges at v1 = %d, expected the edge to the friend", len(edges))
+	}
+
+	cats, err := first.GetEdgeCategories(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"knows"}; !reflect.DeepEqual(cats, expected) {
+		t.Errorf("GetEdgeCategories() at v1 = %#v, expected %#v", cats, expected)
+	}
+
+	if cats, err := first.GetEdgeCategories(friend); err != nil || len(cats) != 0 {
+		t.Errorf("GetEdgeCategories() of the friend at v1 = %#v, %v, expected none", cats, err)
+	}
+
+	// batch loading at the commit, the friend has no properties
+	props, err := first.GetNodesProperties([]string{id, friend}, []string{"Name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 1 || props[id]["Name"] != "Donald" {
+		t.Errorf("GetNodesProperties() at v1 = %#v, expected the name of %s", props, id)
+	}
+
+	texts, err := first.GetNodesTexts([]string{id, friend}, []string{"Bio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts[id]["Bio"] != "a duck" || len(texts[friend]) != 0 {
+		t.Errorf("GetNodesTexts() at v1 = %#v", texts)
+	}
+
+	n.SetString("Name", "Daisy")
+	if err := n.Save(); err != ErrReadOnly {
+		t.Errorf("Save() on snapshot returned %v, expected ErrReadOnly", err)
+	}
+
+	head, err := git.AtTime(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edges, _ := zoom.NewNode(head, id).GetEdges(head, "knows"); len(edges) != 0 {
+		t.Errorf("edges at now = %d, expected none", len(edges))
+	}
+
+	if _, err := git.AtTime(time.Now().Add(-time.Hour)); err == nil {
+		t.Errorf("AtTime() before the first commit should return an error")
+	}
+
+	if _, err := git.At("unknown"); err == nil {
+		t.Errorf("At() with unknown revision should return an error")
+	}
+}
@@ -0,0 +1,104 @@
+package gitstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metakeule/zoom"
+)
+
+// LogFilter selects the commits returned by Git.Log. Empty fields match all commits.
+type LogFilter struct {
+	User    string
+	App     string
+	Command string
+	Since   time.Time // commits not before
+	Until   time.Time // commits not after
+	Node    string    // uuid of a node whose properties, texts or edges have been changed by the commit
+}
+
+// LogEntry is a commit returned by Git.Log
+type LogEntry struct {
+	Commit  string // sha of the commit
+	Time    time.Time
+	Message zoom.CommitMessage // see zoom.ParseCommitMessage
+}
+
+func (f LogFilter) matches(msg zoom.CommitMessage) bool {
+	return (f.User == "" || f.User == msg.User) &&
+		(f.App == "" || f.App == msg.App) &&
+		(f.Command == "" || f.Command == msg.Command)
+}
+
+// Log returns the commits of the repository that match the filter, newest first.
+// Commits whose message has not been written by zoom.CommitMessage.Format only match filters without
+// User, App and Command and have the whole message as Details.
+func (g *Git) Log(filter LogFilter) ([]LogEntry, error) {
+	var args, paths []string
+	if !filter.Since.IsZero() {
+		args = append(args, fmt.Sprintf("--since=@%d", filter.Since.Unix()))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, fmt.Sprintf("--until=@%d", filter.Until.Unix()))
+	}
+
+	if uuid := filter.Node; uuid != "" {
+		if len(uuid) < 3 {
+			return nil, fmt.Errorf("invalid uuid %#v", uuid)
+		}
+		p := &Store{shard: g.shard}
+		paths = []string{p.propPath(uuid), g.textDir(uuid), p.edgePath("*", uuid)}
+	}
+
+	all, err := g.log(args, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	for _, e := range all {
+		if filter.matches(e.Message) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// textDir returns the dir of the text files of the node
+func (g *Git) textDir(uuid string) string {
+	return fmt.Sprintf("text/%s/%s/%s", g.shard, uuid[:2], uuid[2:])
+}
+
+// log returns the commits of the master branch that change one of the given paths (all commits
+// if no path is given), git log is called with the additional args
+func (g *Git) log(args []string, paths []string) ([]LogEntry, error) {
+	args = append([]string{"log", "--format=%H%x00%ct%x00%B%x00"}, args...)
+	args = append(args, "refs/heads/master")
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+
+	out, err := g.git(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		ts, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit time %#v", fields[i+1])
+		}
+
+		msg, _ := zoom.ParseCommitMessage(fields[i+2])
+		entries = append(entries, LogEntry{
+			Commit:  strings.TrimSpace(fields[i]),
+			Time:    time.Unix(ts, 0),
+			Message: msg,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,110 @@
+package gitstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var donald, daisy string
+	create := zoom.CommitMessage{User: "admin", App: "shop", Command: "create", Details: "two\nducks"}
+	err = git.Transaction(create, func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, "")
+		d.SetString("Name", "Donald")
+		donald = d.ID()
+		if err := d.Save(); err != nil {
+			return err
+		}
+		dy := zoom.NewNode(tr, "")
+		dy.SetString("Name", "Daisy")
+		daisy = dy.ID()
+		return dy.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rename := zoom.CommitMessage{Host: "host1", User: "donald", App: "shop", Version: "1.0", Command: "rename"}
+	err = git.Transaction(rename, func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, donald)
+		d.SetString("Name", "Donald Duck")
+		return d.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := zoom.CommitMessage{User: "daisy", App: "shop", Command: "link"}
+	err = git.Transaction(link, func(tr zoom.Transaction) error {
+		return zoom.NewNode(tr, daisy).NewEdge("knows", zoom.NewNode(tr, donald), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := git.Log(LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// including the initial commit
+	if len(all) != 4 {
+		t.Fatalf("Log() returned %d entries, expected 4", len(all))
+	}
+
+	if all[0].Message != link || all[1].Message != rename || all[2].Message != create {
+		t.Errorf("Log() returned %#v", all)
+	}
+
+	if all[0].Commit == "" || time.Since(all[0].Time) > time.Minute {
+		t.Errorf("unexpected commit %#v or time %s", all[0].Commit, all[0].Time)
+	}
+
+	tests := []struct {
+		filter   LogFilter
+		expected []zoom.CommitMessage
+	}{
+		{LogFilter{User: "donald"}, []zoom.CommitMessage{rename}},
+		{LogFilter{App: "shop"}, []zoom.CommitMessage{link, rename, create}},
+		{LogFilter{App: "shop", Command: "create"}, []zoom.CommitMessage{create}},
+		{LogFilter{Node: donald}, []zoom.CommitMessage{rename, create}},
+		{LogFilter{Node: daisy}, []zoom.CommitMessage{link, create}},
+		{LogFilter{Node: daisy, User: "admin"}, []zoom.CommitMessage{create}},
+		{LogFilter{Since: time.Now().Add(time.Hour)}, nil},
+		{LogFilter{Until: time.Now().Add(-time.Hour)}, nil},
+		{LogFilter{App: "shop", Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)}, []zoom.CommitMessage{link, rename, create}},
+	}
+
+	for _, test := range tests {
+		entries, err := git.Log(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != len(test.expected) {
+			t.Errorf("Log(%#v) returned %d entries, expected %d", test.filter, len(entries), len(test.expected))
+			continue
+		}
+
+		for i, e := range entries {
+			if e.Message != test.expected[i] {
+				t.Errorf("Log(%#v)[%d] = %#v, expected %#v", test.filter, i, e.Message, test.expected[i])
+			}
+		}
+	}
+}
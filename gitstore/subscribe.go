@@ -0,0 +1,89 @@
+package gitstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/metakeule/zoom"
+)
+
+// CommitInfo describes a commit made by a transaction of Git
+type CommitInfo struct {
+	Commit  string // sha of the commit
+	Parent  string // sha of the previous commit
+	Message zoom.CommitMessage
+
+	// DiffError is set, if the changes of the commit could not be determined, the Changeset is nil then
+	DiffError error
+}
+
+type subscriptions struct {
+	mx   sync.Mutex
+	next int
+	fns  map[int]func(CommitInfo, *Changeset)
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{fns: map[int]func(CommitInfo, *Changeset){}}
+}
+
+func (s *subscriptions) add(fn func(CommitInfo, *Changeset)) (id int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.next++
+	s.fns[s.next] = fn
+	return s.next
+}
+
+func (s *subscriptions) remove(id int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.fns, id)
+}
+
+// all returns the subscribed functions in the order of subscription
+func (s *subscriptions) all() []func(CommitInfo, *Changeset) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	ids := make([]int, 0, len(s.fns))
+	for id := range s.fns {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fns := make([]func(CommitInfo, *Changeset), len(ids))
+	for i, id := range ids {
+		fns[i] = s.fns[id]
+	}
+	return fns
+}
+
+// Subscribe registers fn to be called after each successful commit of a transaction of g with the commit
+// and its changes (see Diff). fn is called synchronously after the transaction has finished, so it may start
+// new transactions. If the changes could not be determined, fn is called with CommitInfo.DiffError and
+// without Changeset, since the commit has been made anyway. The returned function cancels the subscription.
+func (g *Git) Subscribe(fn func(CommitInfo, *Changeset)) (unsubscribe func()) {
+	id := g.subs.add(fn)
+	return func() {
+		g.subs.remove(id)
+	}
+}
+
+// notify calls the subscribers for the given commit
+func (g *Git) notify(info CommitInfo) {
+	fns := g.subs.all()
+	if len(fns) == 0 {
+		return
+	}
+
+	cs, err := g.Diff(info.Parent, info.Commit)
+	if err != nil {
+		info.DiffError = fmt.Errorf("commit %s has been made, but its changes could not be determined: %s", info.Commit, err)
+	}
+
+	for _, fn := range fns {
+		fn(info, cs)
+	}
+}
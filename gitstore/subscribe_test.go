@@ -0,0 +1,105 @@
+package gitstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/metakeule/zoom"
+)
+
+func TestSubscribe(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var infos []CommitInfo
+	var changes []*Changeset
+	unsubscribe := git.Subscribe(func(info CommitInfo, cs *Changeset) {
+		infos = append(infos, info)
+		changes = append(changes, cs)
+	})
+
+	var id string
+	msg := zoom.CommitMessage{User: "donald", Command: "create"}
+	err = git.Transaction(msg, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("Name", "Donald")
+		id = n.ID()
+		return n.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 1 {
+		t.Fatalf("subscriber has been called %d times, expected once", len(infos))
+	}
+
+	if infos[0].Message != msg || infos[0].Commit == "" || infos[0].Commit == infos[0].Parent {
+		t.Errorf("unexpected commit info %#v", infos[0])
+	}
+
+	if cs := changes[0]; len(cs.Nodes) != 1 || cs.Nodes[0].UUID != id || !cs.Nodes[0].Created {
+		t.Errorf("unexpected changes %#v", cs)
+	}
+
+	// no commit, no call
+	err = git.Transaction(msg, func(tr zoom.Transaction) error {
+		return zoom.ErrNoCommit
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+
+	err = git.Transaction(msg, func(tr zoom.Transaction) error {
+		return zoom.NewNode(tr, id).Remove()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 1 {
+		t.Errorf("subscriber has been called %d times, expected once", len(infos))
+	}
+}
+
+func TestSubscribeDiffError(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gitstore_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := Open(dir, "shard1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var infos []CommitInfo
+	var changes []*Changeset
+	git.Subscribe(func(info CommitInfo, cs *Changeset) {
+		infos = append(infos, info)
+		changes = append(changes, cs)
+	})
+
+	// the changes of an unknown commit can't be determined
+	git.notify(CommitInfo{Commit: "0000000000000000000000000000000000000000", Parent: "master"})
+
+	if len(infos) != 1 {
+		t.Fatalf("subscriber has been called %d times, expected once", len(infos))
+	}
+
+	if infos[0].DiffError == nil || changes[0] != nil {
+		t.Errorf("expected DiffError and no changes, got %v and %#v", infos[0].DiffError, changes[0])
+	}
+}
@@ -1,227 +1,652 @@
 package zoom
 
-// import "github.com/blevesearch/bleve"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+)
 
-// TODO: remove entry from index, have multiple entries per key, delete/add to multiple entries
 /*
-	TODO change index the following way:
+	The basic idea is that the index should be a bytearray that has the following structure
+
+	[len of key in bytes][key][pad until keywidth][len of value][value][pad until valuewidth][len of key in bytes]...
+
+	where len of key in bytes is 1 byte (1 to 255) and len of value is 1 byte (1 to 255) such that
+	one entry is always keywidth+valuewidth+2bytes long where keywidth and valuewidth max at 255 such that
+	one entry is 255+255+2 = 512 bytes at most
 
-	1. to delete an entry set the key to "", if there is an empty key before the to be delete, the first empty key in order
-	   will have the correct number of following empty blocks.
-	   if the entry has no empty key before, it will have the number of following empty keys
+	so that we can scan the file in blocks of keywidth+valuewidth+2bytes
 
-	2. if an entry is added, there will be a lookup, if a value of the key is already there,
-	   if so each following value of the same key is collected until key changes and the keys are
-	   deleted in place (set to ""). then the collected values and the new values will be written in order
-	   at some free place
+	1. to delete an entry the key is blanked (len of key is set to 0). the free slot is reused by the next add.
 
-	3. to get a value, if the key is found, all following values having the same key will be collected
+	2. a key may have multiple values (the uuids of the nodes that have the key as property value)
 
-	4. there is a max number of values for an index that will be respected when adding
-		 if max is reached, adding is an error, instead entries must be deleted before new can be added
-		 a max value of 1 is a unique index for the key
-		 a max value of -1 is no limit for max entries
-		 a max value of 0 should never be there
+	3. there is a max number of values for an index that will be respected when adding
+	   if max is reached, adding is an error, instead entries must be deleted before new can be added
+	   a max value of 1 is a unique index for the key
+	   a max value of -1 is no limit for max entries
+	   a max value of 0 should never be there
 
+	indexes are maintained by a Store that is wrapped via Indexes.Wrap. changes to the indexes are
+	kept in memory until the transaction is committed, then the changed indexes are saved via the
+	IndexStore of the wrapped store (outside of the repository).
 
+	since the indexes are not part of the repository, they are saved in two steps: before the data is
+	committed, they are written to temporary files that are renamed after the commit. if a temporary file
+	is found when the indexes are loaded, the data and the index may disagree and the index is rebuilt
+	from the nodes of the shard (see Indexes.Rebuild).
 */
 
-/*
-// mapping := bleve.NewIndexMapping()
-func CreateIndex(shard, path string) (Index2, error) {
-	mapping := bleve.NewIndexMapping()
-	idx, err := bleve.New(path, mapping)
-	return Index2{idx: idx}, err
+var (
+	StringWidth = 255
+	IntWidth    = 20 // len of strconv.FormatInt(math.MinInt64, 10)
+	// 7196aced-8418-4412-b0ce-4994998aa73f
+	UUIDWidth = 36
+)
+
+// IndexStore is implemented by stores that can save index files
+// outside of the transaction
+type IndexStore interface {
+	SaveIndex(indexpath string, rd io.Reader) error
+
+	// fn is not called, if the index does not exist
+	GetIndex(indexpath string, fn func(io.Reader) error) error
+
+	// RenameIndex renames the index from to the index to, replacing it
+	RenameIndex(from, to string) error
 }
-*/
 
-// index.Index(message.Id, message)
+// tmpIndexPath returns the path of the temporary file of an index, see above
+func tmpIndexPath(indexpath string) string {
+	return indexpath + ".tmp"
+}
+
+// hasIndex returns true, if the index exists
+func hasIndex(is IndexStore, indexpath string) (bool, error) {
+	var has bool
+	err := is.GetIndex(indexpath, func(io.Reader) error {
+		has = true
+		return nil
+	})
+	return has, err
+}
 
-// index, _ := bleve.Open("example.bleve")
+// saveTmpIndexes saves the given index data to the temporary files
+func saveTmpIndexes(is IndexStore, data map[string][]byte) error {
+	for path, d := range data {
+		if err := is.SaveIndex(tmpIndexPath(path), bytes.NewReader(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-/*
-query := bleve.NewQueryStringQuery("bleve")
-    searchRequest := bleve.NewSearchRequest(query)
-    searchResult, _ := index.Search(searchRequest)
-*/
-/*
+// renameTmpIndexes renames the temporary files of the given indexes. If a rename fails,
+// the temporary file is kept, so that the index is rebuilt when it is loaded the next time.
+func renameTmpIndexes(is IndexStore, paths []string) error {
+	var first error
+	for _, path := range paths {
+		if err := is.RenameIndex(tmpIndexPath(path), path); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Unwrapper is implemented by stores that wrap another store
+type Unwrapper interface {
+	Unwrap() Store
+}
+
+// Index is the definition of an index over the values of a node property
 type Index struct {
+	Path       string
+	Property   string
 	KeyWidth   int
 	ValueWidth int
-	Shard      string
-	Path       string
 	MaxNumVals int
-	data       []byte
 }
 
-func NewIndex(shard, path string, keyWidth, valWidth, maxNumVal int) *Index {
+// NewIndex defines an index that is saved in path and maps the values of the
+// given property to the uuids of the nodes
+func NewIndex(path, property string, keyWidth, valWidth, maxNumVals int) *Index {
+	if keyWidth < 1 || keyWidth > 255 || valWidth < 1 || valWidth > 255 {
+		panic("key and value width must be between 1 and 255")
+	}
+	if maxNumVals == 0 || maxNumVals < -1 {
+		panic("maxNumVals must be -1 or > 0")
+	}
 	return &Index{
+		Path:       path,
+		Property:   property,
 		KeyWidth:   keyWidth,
 		ValueWidth: valWidth,
-		Shard:      shard,
-		Path:       path,
-		MaxNumVals: maxNumVal,
+		MaxNumVals: maxNumVals,
 	}
 }
 
-func (i *Index) Save(st Store) error {
-	// fmt.Println(string(i.data))
-	return st.SaveIndex(i.Path, i.Shard, bytes.NewReader(i.data))
+// IndexFullError is returned, if a key of an index already has the maximum number of values,
+// e.g. if the value of a unique property is already taken by another node
+type IndexFullError struct {
+	Index string
+	Key   string
+}
+
+func (i *IndexFullError) Error() string {
+	return fmt.Sprintf("index %s: key %#v has reached the maximum number of values", i.Index, i.Key)
 }
 
-func (i *Index) Load(st Store) error {
-	return st.GetIndex(i.Path, i.Shard, func(rd io.Reader) error {
-		data, err := ioutil.ReadAll(rd)
-		if err == nil {
-			i.data = data
+func (i *Index) entrySize() int {
+	return i.KeyWidth + i.ValueWidth + 2
+}
+
+func (i *Index) entry(data []byte, pos int) (key, value string) {
+	block := data[pos : pos+i.entrySize()]
+	kl := int(block[0])
+	vl := int(block[i.KeyWidth+1])
+	return string(block[1 : 1+kl]), string(block[i.KeyWidth+2 : i.KeyWidth+2+vl])
+}
+
+func (i *Index) writeEntry(block []byte, key, value string) {
+	for j := range block {
+		block[j] = ' '
+	}
+	block[0] = uint8(len(key))
+	copy(block[1:], key)
+	block[i.KeyWidth+1] = uint8(len(value))
+	copy(block[i.KeyWidth+2:], value)
+}
+
+func (i *Index) find(data []byte, key string) (values []string) {
+	for pos := 0; pos+i.entrySize() <= len(data); pos += i.entrySize() {
+		k, v := i.entry(data, pos)
+		if k == key {
+			values = append(values, v)
 		}
-		return err
-	})
+	}
+	return
 }
 
-//func (i *Index) writeString(bf *bytes.Buffer, width int, str string) {
-func (i *Index) writeString(wr io.Writer, width int, str string) error {
-	l := len(str)
-	err := binary.Write(wr, binary.LittleEndian, uint8(l))
-	if err != nil {
-		return err
+func (i *Index) add(data []byte, key, value string) ([]byte, error) {
+	if len(key) == 0 || len(key) > i.KeyWidth {
+		return data, fmt.Errorf("index %s: invalid key length of %#v", i.Path, key)
+	}
+	if len(value) > i.ValueWidth {
+		return data, fmt.Errorf("index %s: value %#v too long", i.Path, value)
+	}
+
+	free := -1
+	var num int
+	for pos := 0; pos+i.entrySize() <= len(data); pos += i.entrySize() {
+		k, v := i.entry(data, pos)
+		switch {
+		case k == "" && free == -1:
+			free = pos
+		case k == key && v == value:
+			return data, nil
+		case k == key:
+			num++
+		}
+	}
+
+	if i.MaxNumVals != -1 && num >= i.MaxNumVals {
+		return data, &IndexFullError{Index: i.Path, Key: key}
 	}
-	rest := width - l
-	for i := rest; i > 0; i-- {
-		str += " "
+
+	if free == -1 {
+		free = len(data)
+		data = append(data, make([]byte, i.entrySize())...)
 	}
-	_, err = wr.Write([]byte(str))
-	return err
+	i.writeEntry(data[free:free+i.entrySize()], key, value)
+	return data, nil
 }
-*/
 
-/*
-	The basic idea is that the index should be a bytearray that has the following structure
+func (i *Index) remove(data []byte, key, value string) {
+	for pos := 0; pos+i.entrySize() <= len(data); pos += i.entrySize() {
+		k, v := i.entry(data, pos)
+		if k == key && v == value {
+			i.writeEntry(data[pos:pos+i.entrySize()], "", "")
+		}
+	}
+}
 
-	[len of key in bytes][key][pad until keywidth][len of value][pad until valuewidth][len of key in bytes]...
+// IndexKeys returns the keys under which a property value is indexed.
+// multi-valued properties are indexed under each of their values,
+// nil values and empty strings are not indexed.
+func IndexKeys(val interface{}) []string {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case int:
+		return []string{strconv.Itoa(v)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case time.Time:
+		return []string{v.UTC().Format(time.RFC3339Nano)}
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return IndexKeys(*v)
+	case []string:
+		var keys []string
+		for _, s := range v {
+			keys = append(keys, IndexKeys(s)...)
+		}
+		return keys
+	case []int64:
+		var keys []string
+		for _, n := range v {
+			keys = append(keys, IndexKeys(n)...)
+		}
+		return keys
+	case []float64:
+		var keys []string
+		for _, f := range v {
+			keys = append(keys, IndexKeys(f)...)
+		}
+		return keys
+	case []bool:
+		var keys []string
+		for _, b := range v {
+			keys = append(keys, IndexKeys(b)...)
+		}
+		return keys
+	case []time.Time:
+		var keys []string
+		for _, t := range v {
+			keys = append(keys, IndexKeys(t)...)
+		}
+		return keys
+	case []interface{}:
+		var keys []string
+		for _, x := range v {
+			keys = append(keys, IndexKeys(x)...)
+		}
+		return keys
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
 
-	where len of key in bytes is 1 byte (1 to 255) and len of value is 1 byte (1 to 255) such that
-	one entry is always keywidth+valuewidth+2bytes long where keywidth and valuewidth max at 255 such that
-	one entry is 255+255+2 = 512 bytes at most
+// Indexes holds the committed data of a set of indexes for one shard.
+// It must be used for all transactions of the shard, so that the indexes stay up to date.
+type Indexes struct {
+	mx      sync.Mutex
+	indexes []*Index
+	data    map[string][]byte // committed data by index path
+}
 
-	so that we can scan the file in blocks of keywidth+valuewidth+2bytes
+func NewIndexes(indexes ...*Index) *Indexes {
+	return &Indexes{indexes: indexes}
+}
 
-*/
+// Wrap returns a store that keeps the indexes up to date, whenever properties are saved or nodes
+// are removed via the returned store. The wrapped store must implement IndexStore.
+func (ix *Indexes) Wrap(st Store) Store {
+	return &indexedStore{Store: st, indexes: ix, staged: map[string][]byte{}}
+}
 
-/*
-func (i *Index) Add(key string, value string) (err error) {
-	var bf bytes.Buffer
-	err = i.add(key, value, &bf)
-	if err == nil {
-		i.data = append(i.data, bf.Bytes()...)
+func findIndexStore(st Store) (IndexStore, bool) {
+	for {
+		if is, ok := st.(IndexStore); ok {
+			return is, true
+		}
+		u, ok := st.(Unwrapper)
+		if !ok {
+			return nil, false
+		}
+		st = u.Unwrap()
 	}
-	return
 }
 
-func (i *Index) add(key string, value string, wr io.Writer) (err error) {
-	if len(key) > i.KeyWidth {
-		panic("key to long " + key)
+// load loads the committed data of the indexes, if not already done.
+// indexes with a temporary file are rebuilt.
+func (ix *Indexes) load(st Store) error {
+	if ix.data != nil {
+		return nil
 	}
-	if len(value) > i.ValueWidth {
-		panic("value to long " + value)
+
+	is, ok := findIndexStore(st)
+	if !ok {
+		return fmt.Errorf("store %T does not support indexes", st)
+	}
+
+	data := map[string][]byte{}
+	var broken []*Index
+	for _, idx := range ix.indexes {
+		tmp, err := hasIndex(is, tmpIndexPath(idx.Path))
+		if err != nil {
+			return err
+		}
+		if tmp {
+			broken = append(broken, idx)
+			continue
+		}
+
+		err = is.GetIndex(idx.Path, func(rd io.Reader) error {
+			b, err := ioutil.ReadAll(rd)
+			data[idx.Path] = b
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(broken) > 0 {
+		rebuilt, err := ix.rebuild(st, is, broken)
+		if err != nil {
+			return err
+		}
+		for path, d := range rebuilt {
+			data[path] = d
+		}
+	}
+	ix.data = data
+	return nil
+}
+
+// rebuild builds the data of the given indexes from the properties of all nodes of the shard and saves it
+func (ix *Indexes) rebuild(st Store, is IndexStore, indexes []*Index) (map[string][]byte, error) {
+	props := make([]string, len(indexes))
+	data := make(map[string][]byte, len(indexes))
+	paths := make([]string, len(indexes))
+	for i, idx := range indexes {
+		props[i] = idx.Property
+		paths[i] = idx.Path
+		data[idx.Path] = []byte{}
+	}
+
+	err := st.ScanNodes("", func(uuid string) error {
+		vals, err := st.GetNodeProperties(uuid, props)
+		if err != nil {
+			return err
+		}
+		for _, idx := range indexes {
+			for _, key := range IndexKeys(vals[idx.Property]) {
+				if data[idx.Path], err = idx.add(data[idx.Path], key, uuid); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveTmpIndexes(is, data); err != nil {
+		return nil, err
+	}
+	return data, renameTmpIndexes(is, paths)
+}
+
+// Rebuild rebuilds all indexes from the committed properties of the nodes of the shard, e.g. after
+// the index files got lost or the indexes have been defined for a shard with existing nodes.
+// st must be the store of a transaction that has no uncommitted changes.
+func (ix *Indexes) Rebuild(st Store) error {
+	ix.mx.Lock()
+	defer ix.mx.Unlock()
+
+	is, ok := findIndexStore(st)
+	if !ok {
+		return fmt.Errorf("store %T does not support indexes", st)
 	}
 
-	err = i.writeString(wr, i.KeyWidth, key)
+	data, err := ix.rebuild(st, is, ix.indexes)
 	if err != nil {
 		return err
 	}
+	ix.data = data
+	return nil
+}
 
-	return i.writeString(wr, i.ValueWidth, value)
+type indexedStore struct {
+	Store
+	indexes *Indexes
+	staged  map[string][]byte // changed data by index path
+}
 
+func (s *indexedStore) Unwrap() Store {
+	return s.Store
 }
 
-func (i *Index) Find(key string) (value string, err error) {
-	bf := bytes.NewBuffer(i.data)
-	return i.find(key, bf)
+// indexData returns a copy of the current data of the index, so that changes are only
+// visible after they have been staged
+func (s *indexedStore) indexData(idx *Index) ([]byte, error) {
+	if data, has := s.staged[idx.Path]; has {
+		return append([]byte(nil), data...), nil
+	}
+
+	s.indexes.mx.Lock()
+	defer s.indexes.mx.Unlock()
+
+	if err := s.indexes.load(s.Store); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), s.indexes.data[idx.Path]...), nil
 }
 
-// TODO: take a writeseeker in order to write at a specific position
-func (i *Index) remove(key string) (err error) {
-*/
-/*
-	buf := make([]byte, i.KeyWidth+i.ValueWidth+2)
-	var block int
-	var n int
-	for {
-		n, err = rd.Read(buf)
-		if err != nil && err != io.EOF {
-			return "", err
-		}
-		if n == 0 {
-			break
+// oldValues returns the saved values of the indexed properties of the node
+func (s *indexedStore) oldValues(uuid string, props []string) map[string]interface{} {
+	old, err := s.Store.GetNodeProperties(uuid, props)
+	if err != nil {
+		// the node does not exist yet
+		return map[string]interface{}{}
+	}
+	return old
+}
+
+func (s *indexedStore) SaveNodeProperties(uuid string, props map[string]interface{}) error {
+	var affected []*Index
+	var affectedProps []string
+	for _, idx := range s.indexes.indexes {
+		if _, has := props[idx.Property]; has {
+			affected = append(affected, idx)
+			affectedProps = append(affectedProps, idx.Property)
 		}
+	}
 
-		bff := bytes.NewBuffer(buf)
-		var l uint8
+	if len(affected) == 0 {
+		return s.Store.SaveNodeProperties(uuid, props)
+	}
 
-		err = binary.Read(bff, binary.LittleEndian, &l)
+	old := s.oldValues(uuid, affectedProps)
+	changed := map[string][]byte{}
+
+	for _, idx := range affected {
+		data, err := s.indexData(idx)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		k := string(buf[1 : l+1])
+		for _, key := range IndexKeys(old[idx.Property]) {
+			idx.remove(data, key, uuid)
+		}
 
-		if k == key {
-			var l2 uint8
-			bff = bytes.NewBuffer(buf[i.KeyWidth+1 : i.KeyWidth+2])
-			err = binary.Read(bff, binary.LittleEndian, &l2)
+		for _, key := range IndexKeys(props[idx.Property]) {
+			data, err = idx.add(data, key, uuid)
 			if err != nil {
-				return "", err
+				return err
 			}
-			return string(buf[i.KeyWidth+2 : i.KeyWidth+2+int(l2)]), nil
 		}
-		block++
+		changed[idx.Path] = data
 	}
-	return "", nil
-*/
-/*	return
+
+	if err := s.Store.SaveNodeProperties(uuid, props); err != nil {
+		return err
+	}
+
+	for path, data := range changed {
+		s.staged[path] = data
+	}
+	return nil
 }
 
-func (i *Index) find(key string, rd io.Reader) (value string, err error) {
+// unindex returns the changed data of the indexes without the entries of the given nodes
+func (s *indexedStore) unindex(uuids []string) (map[string][]byte, error) {
+	var props []string
+	for _, idx := range s.indexes.indexes {
+		props = append(props, idx.Property)
+	}
 
-	buf := make([]byte, i.KeyWidth+i.ValueWidth+2)
-	var n int
-	for {
-		n, err = rd.Read(buf)
-		if err != nil && err != io.EOF {
-			return "", err
-		}
-		if n == 0 {
-			break
+	changed := map[string][]byte{}
+	for _, uuid := range uuids {
+		old := s.oldValues(uuid, props)
+
+		for _, idx := range s.indexes.indexes {
+			keys := IndexKeys(old[idx.Property])
+			if len(keys) == 0 {
+				continue
+			}
+
+			data, has := changed[idx.Path]
+			if !has {
+				var err error
+				if data, err = s.indexData(idx); err != nil {
+					return nil, err
+				}
+			}
+
+			for _, key := range keys {
+				idx.remove(data, key, uuid)
+			}
+			changed[idx.Path] = data
 		}
+	}
+	return changed, nil
+}
+
+func (s *indexedStore) RemoveNode(uuid string) error {
+	changed, err := s.unindex([]string{uuid})
+	if err != nil {
+		return err
+	}
 
-		bff := bytes.NewBuffer(buf)
-		var l uint8
+	if err := s.Store.RemoveNode(uuid); err != nil {
+		return err
+	}
 
-		err = binary.Read(bff, binary.LittleEndian, &l)
-		if err != nil {
-			return "", err
+	for path, data := range changed {
+		s.staged[path] = data
+	}
+	return nil
+}
+
+// RemoveEdges removes the property nodes of the edges from the indexes, since the store removes them
+func (s *indexedStore) RemoveEdges(category, fromUUID string) error {
+	propIDs, err := edgePropertyNodes(s.Store, category, fromUUID)
+	if err != nil {
+		return err
+	}
+
+	changed, err := s.unindex(propIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store.RemoveEdges(category, fromUUID); err != nil {
+		return err
+	}
+
+	for path, data := range changed {
+		s.staged[path] = data
+	}
+	return nil
+}
+
+// Find returns the uuids of the nodes that have the given value for the property of the index with the given path
+func (s *indexedStore) Find(indexpath string, value interface{}) ([]string, error) {
+	for _, idx := range s.indexes.indexes {
+		if idx.Path != indexpath {
+			continue
 		}
 
-		k := string(buf[1 : l+1])
+		data, err := s.indexData(idx)
+		if err != nil {
+			return nil, err
+		}
 
-		if k == key {
-			var l2 uint8
-			bff = bytes.NewBuffer(buf[i.KeyWidth+1 : i.KeyWidth+2])
-			err = binary.Read(bff, binary.LittleEndian, &l2)
-			if err != nil {
-				return "", err
-			}
-			return string(buf[i.KeyWidth+2 : i.KeyWidth+2+int(l2)]), nil
+		var uuids []string
+		for _, key := range IndexKeys(value) {
+			uuids = append(uuids, idx.find(data, key)...)
 		}
+		return uuids, nil
 	}
-	return "", nil
+	return nil, fmt.Errorf("unknown index %#v", indexpath)
 }
 
-var (
-	StringWidth = 255
-	IntWidth    = 10 // TODO: lookup
-	// 7196aced-8418-4412-b0ce-4994998aa73f
-	UUIDWidth = 36
-)
-*/
+// Commit saves the staged indexes to their temporary files, commits the data and renames the
+// temporary files. If the renaming fails, the data is committed and the indexes in memory are up
+// to date, the index files are rebuilt when they are loaded the next time.
+func (s *indexedStore) Commit(msg CommitMessage) error {
+	if len(s.staged) == 0 {
+		return s.Store.Commit(msg)
+	}
+
+	is, ok := findIndexStore(s.Store)
+	if !ok {
+		return fmt.Errorf("store %T does not support indexes", s.Store)
+	}
+
+	s.indexes.mx.Lock()
+	defer s.indexes.mx.Unlock()
+
+	if err := saveTmpIndexes(is, s.staged); err != nil {
+		return err
+	}
+
+	if err := s.Store.Commit(msg); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(s.staged))
+	for path, data := range s.staged {
+		s.indexes.data[path] = data
+		paths = append(paths, path)
+	}
+	s.staged = map[string][]byte{}
+	renameTmpIndexes(is, paths)
+	return nil
+}
+
+func (s *indexedStore) Rollback() error {
+	s.staged = map[string][]byte{}
+	return s.Store.Rollback()
+}
+
+// Finder is implemented by stores that can look up nodes by index
+type Finder interface {
+	Find(indexpath string, value interface{}) (uuids []string, err error)
+}
+
+// FindByIndex returns the uuids of the nodes that have the given value inside the index with the given path.
+// The transaction must be a store that has been wrapped by Indexes.Wrap.
+func FindByIndex(tr Transaction, indexpath string, value interface{}) ([]string, error) {
+	var st interface{} = tr
+	for {
+		if f, ok := st.(Finder); ok {
+			return f.Find(indexpath, value)
+		}
+		u, ok := st.(Unwrapper)
+		if !ok {
+			return nil, fmt.Errorf("transaction %T has no indexes", tr)
+		}
+		st = u.Unwrap()
+	}
+}
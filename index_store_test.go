@@ -0,0 +1,264 @@
+package zoom_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func TestIndexes(t *testing.T) {
+	mem := memstore.New("shard1")
+	indexes := zoom.NewIndexes(
+		zoom.NewIndex("email", "Email", zoom.StringWidth, zoom.UUIDWidth, 1),
+		zoom.NewIndex("tags", "Tags", zoom.StringWidth, zoom.UUIDWidth, -1),
+	)
+
+	transaction := func(action func(zoom.Transaction) error) error {
+		return zoom.NewTransaction(indexes.Wrap(mem), zoom.CommitMessage{Command: "test"}, action)
+	}
+
+	find := func(indexpath string, value interface{}) []string {
+		var uuids []string
+		err := transaction(func(tr zoom.Transaction) (err error) {
+			uuids, err = zoom.FindByIndex(tr, indexpath, value)
+			if err != nil {
+				return err
+			}
+			return zoom.ErrNoCommit
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(uuids)
+		return uuids
+	}
+
+	var donald, daisy string
+
+	err := transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, "")
+		donald = d.ID()
+		d.SetString("Email", "donald@duck.com")
+		if err := d.SetStrings("Tags", "duck", "famous"); err != nil {
+			return err
+		}
+		if err := d.Save(); err != nil {
+			return err
+		}
+
+		d = zoom.NewNode(tr, "")
+		daisy = d.ID()
+		d.SetString("Email", "daisy@duck.com")
+		if err := d.SetStrings("Tags", "duck"); err != nil {
+			return err
+		}
+		return d.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("email", "donald@duck.com"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("email donald@duck.com = %v, expected %v", got, []string{donald})
+	}
+
+	expected := []string{donald, daisy}
+	sort.Strings(expected)
+
+	if got := find("tags", "duck"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("tags duck = %v, expected %v", got, expected)
+	}
+
+	// unique violation
+	err = transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, daisy)
+		d.SetString("Email", "donald@duck.com")
+		return d.Save()
+	})
+
+	if _, ok := err.(*zoom.IndexFullError); !ok {
+		t.Errorf("expected *zoom.IndexFullError, got %T %v", err, err)
+	}
+
+	if got := find("email", "daisy@duck.com"); !reflect.DeepEqual(got, []string{daisy}) {
+		t.Errorf("email daisy@duck.com after rollback = %v, expected %v", got, []string{daisy})
+	}
+
+	// changing a value
+	err = transaction(func(tr zoom.Transaction) error {
+		d := zoom.NewNode(tr, donald)
+		d.SetString("Email", "donald@entenhausen.de")
+		return d.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("email", "donald@duck.com"); len(got) != 0 {
+		t.Errorf("email donald@duck.com = %v, expected none", got)
+	}
+
+	if got := find("email", "donald@entenhausen.de"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("email donald@entenhausen.de = %v, expected %v", got, []string{donald})
+	}
+
+	// removing a node
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveNode(donald)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("tags", "duck"); !reflect.DeepEqual(got, []string{daisy}) {
+		t.Errorf("tags duck after remove = %v, expected %v", got, []string{daisy})
+	}
+
+	if got := find("tags", "famous"); len(got) != 0 {
+		t.Errorf("tags famous after remove = %v, expected none", got)
+	}
+
+	// the property nodes of removed edges
+	err = transaction(func(tr zoom.Transaction) error {
+		return zoom.NewNode(tr, daisy).NewEdge("knows", zoom.NewNode(tr, ""), map[string]interface{}{"Tags": []string{"friend"}})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("tags", "friend"); len(got) != 1 {
+		t.Errorf("tags friend = %v, expected the property node of the edge", got)
+	}
+
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveEdges("knows", daisy)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("tags", "friend"); len(got) != 0 {
+		t.Errorf("tags friend after removing the edges = %v, expected none", got)
+	}
+
+	// the indexes are persisted in the store
+	fresh := zoom.NewIndexes(zoom.NewIndex("email", "Email", zoom.StringWidth, zoom.UUIDWidth, 1))
+	var got []string
+	err = zoom.NewTransaction(fresh.Wrap(mem), zoom.CommitMessage{}, func(tr zoom.Transaction) (err error) {
+		got, err = zoom.FindByIndex(tr, "email", "daisy@duck.com")
+		return
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, []string{daisy}) {
+		t.Errorf("persisted email daisy@duck.com = %v, expected %v", got, []string{daisy})
+	}
+}
+
+// brokenIndexStore lets the renaming of the index files or the commit of the data fail
+type brokenIndexStore struct {
+	zoom.Store
+	mem        *memstore.Store
+	failRename bool
+	failCommit bool
+	saves      int // number of saved index files
+}
+
+func (b *brokenIndexStore) SaveIndex(indexpath string, rd io.Reader) error {
+	b.saves++
+	return b.mem.SaveIndex(indexpath, rd)
+}
+
+func (b *brokenIndexStore) GetIndex(indexpath string, fn func(io.Reader) error) error {
+	return b.mem.GetIndex(indexpath, fn)
+}
+
+func (b *brokenIndexStore) RenameIndex(from, to string) error {
+	if b.failRename {
+		return errors.New("rename failed")
+	}
+	return b.mem.RenameIndex(from, to)
+}
+
+func (b *brokenIndexStore) Commit(msg zoom.CommitMessage) error {
+	if b.failCommit {
+		b.Store.Rollback()
+		return errors.New("commit failed")
+	}
+	return b.Store.Commit(msg)
+}
+
+func TestIndexesRebuild(t *testing.T) {
+	mem := memstore.New("shard1")
+	broken := &brokenIndexStore{Store: mem, mem: mem}
+
+	save := func(uuid, email string) error {
+		indexes := zoom.NewIndexes(zoom.NewIndex("email", "Email", zoom.StringWidth, zoom.UUIDWidth, 1))
+		return zoom.NewTransaction(indexes.Wrap(broken), zoom.CommitMessage{}, func(tr zoom.Transaction) error {
+			d := zoom.NewNode(tr, uuid)
+			d.SetString("Email", email)
+			return d.Save()
+		})
+	}
+
+	find := func(email string) []string {
+		indexes := zoom.NewIndexes(zoom.NewIndex("email", "Email", zoom.StringWidth, zoom.UUIDWidth, 1))
+		var got []string
+		err := zoom.NewTransaction(indexes.Wrap(mem), zoom.CommitMessage{}, func(tr zoom.Transaction) (err error) {
+			got, err = zoom.FindByIndex(tr, "email", email)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	donald := "0e5c1e2a-a4f7-4b4e-9d3b-7d5b8a1f2c3d"
+	if err := save(donald, "donald@duck.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data is committed, the index is rebuilt when it is loaded the next time
+	broken.failRename = true
+	if err := save(donald, "donald@entenhausen.de"); err != nil {
+		t.Fatalf("commit with failing rename: %v", err)
+	}
+	broken.failRename = false
+
+	if got := find("donald@entenhausen.de"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("email donald@entenhausen.de after rebuild = %v, expected %v", got, []string{donald})
+	}
+
+	if got := find("donald@duck.com"); len(got) != 0 {
+		t.Errorf("email donald@duck.com after rebuild = %v, expected none", got)
+	}
+
+	// the data is not committed, the index must not contain the new value
+	broken.failCommit = true
+	if err := save(donald, "donald@duckburg.com"); err == nil {
+		t.Fatal("expected commit error")
+	}
+	broken.failCommit = false
+
+	if got := find("donald@duckburg.com"); len(got) != 0 {
+		t.Errorf("email donald@duckburg.com after failed commit = %v, expected none", got)
+	}
+
+	if got := find("donald@entenhausen.de"); !reflect.DeepEqual(got, []string{donald}) {
+		t.Errorf("email donald@entenhausen.de after failed commit = %v, expected %v", got, []string{donald})
+	}
+}
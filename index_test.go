@@ -1,8 +1,7 @@
 package zoom
 
-/*
 import (
-	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -17,70 +16,61 @@ func TestAddIndex(t *testing.T) {
 		{"Mickey", "Mouse"},
 	}
 
-	var bf bytes.Buffer
+	var data []byte
 	var err error
 
 	for _, test := range tests {
-		err = idx.add(test.first, test.last, &bf)
+		data, err = idx.add(data, test.first, test.last)
 		if err != nil {
 			t.Errorf("can't add %s %s: %s", test.first, test.last, err.Error())
 		}
 	}
 
-	vals := bf.Bytes()
-	var res string
-
 	for _, test := range tests {
-		res, err = idx.find(test.first, bytes.NewReader(vals))
-
-		if err != nil {
-			t.Errorf("can't find %s: %s", test.first, err.Error())
-		}
+		res := idx.find(data, test.first)
 
-		if res != test.last {
-			t.Errorf("can't find %s: expected: %s, got: %s", test.first, test.last, res)
+		if !reflect.DeepEqual(res, []string{test.last}) {
+			t.Errorf("can't find %s: expected: %v, got: %v", test.first, []string{test.last}, res)
 		}
-
 	}
 
+	if _, err = idx.add(data, "Donald", "Trump"); err == nil {
+		t.Errorf("expected error when adding second value for unique key")
+	}
 }
 
 func TestRemoveIndex(t *testing.T) {
-	idx := NewIndex("", "", 255, 255, 1)
-
-	tests := []struct {
-		first string
-		last  string
-	}{
-		{"Donald", "Duck"},
-		{"Mickey", "Mouse"},
-	}
+	idx := NewIndex("", "", 20, 36, -1)
 
-	var bf bytes.Buffer
+	var data []byte
 	var err error
 
-	for _, test := range tests {
-		err = idx.add(test.first, test.last, &bf)
+	for _, last := range []string{"Duck", "Trump", "Knuth"} {
+		data, err = idx.add(data, "Donald", last)
 		if err != nil {
-			t.Errorf("can't add %s %s: %s", test.first, test.last, err.Error())
+			t.Fatalf("can't add Donald %s: %s", last, err.Error())
 		}
 	}
 
-	vals := bf.Bytes()
-	var res string
+	idx.remove(data, "Donald", "Trump")
 
-	for _, test := range tests {
-		res, err = idx.find(test.first, bytes.NewReader(vals))
+	if res := idx.find(data, "Donald"); !reflect.DeepEqual(res, []string{"Duck", "Knuth"}) {
+		t.Errorf("after remove expected: %v, got: %v", []string{"Duck", "Knuth"}, res)
+	}
 
-		if err != nil {
-			t.Errorf("can't find %s: %s", test.first, err.Error())
-		}
+	size := len(data)
 
-		if res != test.last {
-			t.Errorf("can't find %s: expected: %s, got: %s", test.first, test.last, res)
-		}
+	// the free slot is reused
+	data, err = idx.add(data, "Mickey", "Mouse")
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	if len(data) != size {
+		t.Errorf("free slot was not reused: size before %d, after %d", size, len(data))
 	}
 
+	if res := idx.find(data, "Mickey"); !reflect.DeepEqual(res, []string{"Mouse"}) {
+		t.Errorf("can't find Mickey: got %v", res)
+	}
 }
-*/
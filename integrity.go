@@ -0,0 +1,261 @@
+package zoom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	referential integrity
+
+	By default RemoveNode does not care about edges that point to the removed node. A Store that is
+	wrapped via Integrity.Wrap checks the incoming edges of a node before it is removed and handles them
+	according to the OnRemove rule of their category:
+
+		Restrict  the node can't be removed while it has incoming edges of the category
+		Cascade   the incoming edges and their property nodes are removed together with the node
+		Nullify   the incoming edges are removed together with the node, but their property nodes are kept
+		          as tombstones (see NullifiedProperty)
+
+	Incoming edges of categories without rule are left alone.
+	Only incoming edges from nodes of the same shard can be removed. If a node has incoming edges of a
+	Cascade or Nullify category from other shards, it can't be removed and these edges must be removed before.
+
+	The edges and property nodes are changed via the outermost store of the transaction, so that the wrappers
+	around the integrity store (e.g. Indexes) see the changes. NewTransaction binds the outermost store, so the
+	transaction must be run via NewTransaction (as Git.Transaction and memstore.Store.Transaction do).
+	Otherwise the changes are made via the wrapped store and bypass the wrappers around the integrity store.
+
+	The tombstones of the Nullify rule are kept until they are removed with RemoveTombstones.
+*/
+
+// NullifiedProperty is set on the property node of an edge that has been removed by the Nullify rule.
+// Its value is a []string of the category of the edge, the id of the source node with the id of the edge
+// (see EdgeKey) and the id of the removed target node. Tombstones can be found by indexing this property
+// and are removed with RemoveTombstones.
+const NullifiedProperty = "NullifiedEdge"
+
+// OnRemove defines what happens with the incoming edges of a node that is removed
+type OnRemove int
+
+const (
+	Restrict OnRemove = iota
+	Cascade
+	Nullify
+)
+
+func (o OnRemove) String() string {
+	switch o {
+	case Restrict:
+		return "restrict"
+	case Cascade:
+		return "cascade"
+	case Nullify:
+		return "nullify"
+	}
+	return fmt.Sprintf("OnRemove(%d)", int(o))
+}
+
+// IntegrityError is returned, if a node can't be removed because of its incoming edges
+type IntegrityError struct {
+	NodeID   string
+	Category string
+	Rule     OnRemove
+	From     []string // keys of the incoming edges ("shard-uuid" of the source nodes, see EdgeKey)
+}
+
+func (i *IntegrityError) Error() string {
+	if i.Rule == Restrict {
+		return fmt.Sprintf("node %s can't be removed: it has incoming %s edges from %s", i.NodeID, i.Category, strings.Join(i.From, ", "))
+	}
+	return fmt.Sprintf("node %s can't be removed: incoming %s edges from other shards (%s) must be removed before", i.NodeID, i.Category, strings.Join(i.From, ", "))
+}
+
+// Integrity holds the OnRemove rules per edge category
+type Integrity struct {
+	rules map[string]OnRemove
+}
+
+func NewIntegrity(rules map[string]OnRemove) *Integrity {
+	r := make(map[string]OnRemove, len(rules))
+	for cat, rule := range rules {
+		r[cat] = rule
+	}
+	return &Integrity{rules: r}
+}
+
+// Wrap returns a store that applies the rules, whenever a node is removed via the returned store.
+// The wrappers around the returned store only see the removed edges and property nodes inside NewTransaction.
+func (i *Integrity) Wrap(st Store) Store {
+	return &integrityStore{Store: st, integrity: i}
+}
+
+type integrityStore struct {
+	Store
+	integrity *Integrity
+	outer     Store // the outermost store of the transaction, see bindOuter
+}
+
+func (s *integrityStore) Unwrap() Store {
+	return s.Store
+}
+
+func (s *integrityStore) bindOuter(outer Store) {
+	s.outer = outer
+}
+
+// changes returns the store the edges and property nodes are changed with
+func (s *integrityStore) changes() Store {
+	if s.outer != nil {
+		return s.outer
+	}
+	return s.Store
+}
+
+// incoming returns the keys of the incoming edges of the given category, separated by
+// the edges from the same shard and the edges from other shards.
+func (s *integrityStore) incoming(category, uuid string) (local, foreign []string, err error) {
+	in, err := s.Store.GetIncomingEdges(category, uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for from := range in {
+		shard, _, _, err := SplitEdgeKey(from)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if shard != s.Store.Shard() {
+			foreign = append(foreign, from)
+			continue
+		}
+		local = append(local, from)
+	}
+	sort.Strings(local)
+	sort.Strings(foreign)
+	return local, foreign, nil
+}
+
+// removeEdge removes the edge with the given incoming key to the node with the given uuid from the edges of the source node.
+// the property node of the edge is removed or, if rule is Nullify, kept as tombstone
+func (s *integrityStore) removeEdge(category, incomingKey, uuid string, rule OnRemove) error {
+	st := s.changes()
+	_, fromUUID, id, err := SplitEdgeKey(incomingKey)
+	if err != nil {
+		return err
+	}
+
+	edges, err := st.GetEdges(category, fromUUID)
+	if err != nil {
+		return err
+	}
+
+	target := st.Shard() + "-" + uuid
+	key := EdgeKey(target, id)
+	propID, _, _, err := ParseEdgeValue(edges[key])
+	if err != nil {
+		return err
+	}
+
+	if propID != "" {
+		if rule == Nullify {
+			err = st.SaveNodeProperties(propID, map[string]interface{}{NullifiedProperty: []string{category, incomingKey, target}})
+		} else {
+			err = st.RemoveNode(propID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(edges, key)
+	return saveRemainingEdges(st, category, fromUUID, edges)
+}
+
+func (s *integrityStore) RemoveNode(uuid string) error {
+	categories := make([]string, 0, len(s.integrity.rules))
+	for cat := range s.integrity.rules {
+		categories = append(categories, cat)
+	}
+	sort.Strings(categories)
+
+	// check all rules before changing anything
+	local := map[string][]string{}
+	for _, cat := range categories {
+		rule := s.integrity.rules[cat]
+		l, foreign, err := s.incoming(cat, uuid)
+		if err != nil {
+			return err
+		}
+
+		if rule == Restrict && len(l)+len(foreign) > 0 {
+			from := append(l, foreign...)
+			sort.Strings(from)
+			return &IntegrityError{NodeID: uuid, Category: cat, Rule: rule, From: from}
+		}
+
+		if len(foreign) > 0 {
+			return &IntegrityError{NodeID: uuid, Category: cat, Rule: rule, From: foreign}
+		}
+		local[cat] = l
+	}
+
+	for _, cat := range categories {
+		for _, from := range local[cat] {
+			if err := s.removeEdge(cat, from, uuid, s.integrity.rules[cat]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.Store.RemoveNode(uuid)
+}
+
+// RemoveTombstones removes the property nodes that have been kept as tombstones by the Nullify rule
+// (see NullifiedProperty) and returns their uuids. All nodes of the shard are scanned.
+func RemoveTombstones(tr Transaction) ([]string, error) {
+	var tombstones []string
+	err := tr.ScanNodes("", func(uuid string) error {
+		props, err := tr.GetNodeProperties(uuid, []string{NullifiedProperty})
+		if err != nil {
+			return err
+		}
+		if _, has := props[NullifiedProperty]; has {
+			tombstones = append(tombstones, uuid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uuid := range tombstones {
+		if err := tr.RemoveNode(uuid); err != nil {
+			return nil, err
+		}
+	}
+	return tombstones, nil
+}
+
+// outerBinder is implemented by wrapping stores that need the outermost store of a transaction.
+// NewTransaction binds it before the action is run
+type outerBinder interface {
+	bindOuter(outer Store)
+}
+
+// bindOuter passes the outermost store st to the stores wrapped by st that need it
+func bindOuter(st Store) {
+	var s interface{} = st
+	for {
+		if b, ok := s.(outerBinder); ok {
+			b.bindOuter(st)
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			return
+		}
+		s = u.Unwrap()
+	}
+}
@@ -0,0 +1,260 @@
+package zoom_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func TestIntegrity(t *testing.T) {
+	mem := memstore.New("shard1")
+	integrity := zoom.NewIntegrity(map[string]zoom.OnRemove{
+		"owner":  zoom.Restrict,
+		"member": zoom.Cascade,
+		"likes":  zoom.Nullify,
+	})
+
+	transaction := func(action func(zoom.Transaction) error) error {
+		return zoom.NewTransaction(integrity.Wrap(mem), zoom.CommitMessage{Command: "test"}, action)
+	}
+
+	var group, donald, daisy, likeProps string
+
+	err := transaction(func(tr zoom.Transaction) error {
+		g := zoom.NewNode(tr, "")
+		g.SetString("Name", "ducks")
+		d := zoom.NewNode(tr, "")
+		d.SetString("Name", "Donald")
+		y := zoom.NewNode(tr, "")
+		y.SetString("Name", "Daisy")
+		for _, n := range []*zoom.Node{g, d, y} {
+			if err := n.Save(); err != nil {
+				return err
+			}
+		}
+		group, donald, daisy = g.ID(), d.ID(), y.ID()
+
+		if err := d.NewEdge("member", g, map[string]interface{}{"Since": "1934"}); err != nil {
+			return err
+		}
+		if err := y.NewEdge("member", g, nil); err != nil {
+			return err
+		}
+		if err := y.NewEdge("likes", d, map[string]interface{}{"Much": true}); err != nil {
+			return err
+		}
+		e, err := y.GetEdge("likes", d)
+		if err != nil {
+			return err
+		}
+		likeProps = e.Properties.ID()
+		return g.NewEdge("owner", d, nil)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restrict: donald is owner of the group
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveNode(donald)
+	})
+
+	if ie, ok := err.(*zoom.IntegrityError); !ok || ie.Category != "owner" || ie.Rule != zoom.Restrict {
+		t.Fatalf("expected *zoom.IntegrityError for category owner, got %T %v", err, err)
+	}
+
+	if _, err := mem.GetNodeProperties(donald, []string{"Name"}); err != nil {
+		t.Errorf("restricted node has been removed")
+	}
+
+	// cascade: removing the group removes the member edges with their property nodes
+	var memberProps string
+	err = transaction(func(tr zoom.Transaction) error {
+		e, err := zoom.NewNode(tr, donald).GetEdge("member", zoom.NewNode(tr, group))
+		if err != nil {
+			return err
+		}
+		memberProps = e.Properties.ID()
+		return tr.RemoveNode(group)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{donald, daisy} {
+		if edges, _ := mem.GetEdges("member", id); len(edges) != 0 {
+			t.Errorf("member edges of %s still exist: %v", id, edges)
+		}
+	}
+
+	if _, err := mem.GetNodeProperties(memberProps, []string{"Since"}); err == nil {
+		t.Errorf("property node of cascaded edge still exists")
+	}
+
+	// nullify: removing donald removes the likes edge of daisy but keeps the property node
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveNode(donald)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if edges, _ := mem.GetEdges("likes", daisy); len(edges) != 0 {
+		t.Errorf("likes edges of daisy still exist: %v", edges)
+	}
+
+	tombstone, err := mem.GetNodeProperties(likeProps, []string{"Much", zoom.NullifiedProperty})
+	if err != nil {
+		t.Fatalf("property node of nullified edge has been removed")
+	}
+
+	expected := map[string]interface{}{
+		"Much":                 true,
+		zoom.NullifiedProperty: []string{"likes", "shard1-" + daisy, "shard1-" + donald},
+	}
+
+	if !reflect.DeepEqual(tombstone, expected) {
+		t.Errorf("tombstone = %#v, expected %#v", tombstone, expected)
+	}
+
+	var removed []string
+	err = transaction(func(tr zoom.Transaction) (err error) {
+		removed, err = zoom.RemoveTombstones(tr)
+		return
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(removed, []string{likeProps}) {
+		t.Errorf("RemoveTombstones() = %v, expected %v", removed, []string{likeProps})
+	}
+
+	if _, err := mem.GetNodeProperties(likeProps, []string{"Much"}); err == nil {
+		t.Errorf("tombstone still exists after RemoveTombstones")
+	}
+
+	if _, err := mem.GetNodeProperties(daisy, []string{"Name"}); err != nil {
+		t.Errorf("RemoveTombstones removed daisy")
+	}
+}
+
+func TestIntegrityOuterWrappers(t *testing.T) {
+	mem := memstore.New("shard1")
+	integrity := zoom.NewIntegrity(map[string]zoom.OnRemove{"member": zoom.Cascade})
+	indexes := zoom.NewIndexes(zoom.NewIndex("since", "Since", zoom.StringWidth, zoom.UUIDWidth, -1))
+
+	transaction := func(action func(zoom.Transaction) error) error {
+		return zoom.NewTransaction(indexes.Wrap(integrity.Wrap(mem)), zoom.CommitMessage{}, action)
+	}
+
+	find := func(value string) []string {
+		var uuids []string
+		err := transaction(func(tr zoom.Transaction) (err error) {
+			uuids, err = zoom.FindByIndex(tr, "since", value)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuids
+	}
+
+	var group string
+	err := transaction(func(tr zoom.Transaction) error {
+		g := zoom.NewNode(tr, "")
+		d := zoom.NewNode(tr, "")
+		group = g.ID()
+		return d.NewEdge("member", g, map[string]interface{}{"Since": "1934"})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := find("1934"); len(got) != 1 {
+		t.Fatalf("index of property nodes = %v, expected one node", got)
+	}
+
+	err = transaction(func(tr zoom.Transaction) error {
+		return tr.RemoveNode(group)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the indexes wrap the integrity store and see the removal of the property node
+	if got := find("1934"); len(got) != 0 {
+		t.Errorf("index after cascade = %v, expected none", got)
+	}
+}
+
+func TestIntegrityForeignShard(t *testing.T) {
+	groups := memstore.New("groups")
+	persons := memstore.New("persons")
+	integrity := zoom.NewIntegrity(map[string]zoom.OnRemove{"member": zoom.Cascade})
+
+	group := zoom.NewNode(groups, "")
+	donald := zoom.NewNode(persons, "")
+	for _, n := range []*zoom.Node{group, donald} {
+		n.SetString("Name", "x")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := donald.NewEdge("member", group, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := zoom.NewTransaction(integrity.Wrap(groups), zoom.CommitMessage{}, func(tr zoom.Transaction) error {
+		return tr.RemoveNode(group.ID())
+	})
+
+	if _, ok := err.(*zoom.IntegrityError); !ok {
+		t.Errorf("expected *zoom.IntegrityError for edges from other shard, got %T %v", err, err)
+	}
+}
+
+func TestIntegrityMultiEdges(t *testing.T) {
+	mem := memstore.New("shard1")
+	integrity := zoom.NewIntegrity(map[string]zoom.OnRemove{"paid": zoom.Cascade})
+
+	var shop, donald string
+	var props []string
+
+	err := zoom.NewTransaction(integrity.Wrap(mem), zoom.CommitMessage{}, func(tr zoom.Transaction) error {
+		s := zoom.NewNode(tr, "")
+		d := zoom.NewNode(tr, "")
+		shop, donald = s.ID(), d.ID()
+		for _, amount := range []string{"5", "7"} {
+			e, err := d.AddEdge("paid", s, map[string]interface{}{"Amount": amount})
+			if err != nil {
+				return err
+			}
+			props = append(props, e.Properties.ID())
+		}
+		return tr.RemoveNode(shop)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if edges, _ := mem.GetEdges("paid", donald); len(edges) != 0 {
+		t.Errorf("edges after cascade = %v, expected none", edges)
+	}
+
+	for _, id := range props {
+		if _, err := mem.GetNodeProperties(id, []string{"Amount"}); err == nil {
+			t.Errorf("property node %s still exists", id)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package zoom
+
+// BatchLoader is implemented by stores that can load the properties and texts of many nodes at once.
+// Nodes that do not exist are not part of the returned maps.
+type BatchLoader interface {
+	GetNodesProperties(uuids []string, requestedProps []string) (props map[string]map[string]interface{}, err error)
+	GetNodesTexts(uuids []string, requestedTexts []string) (texts map[string]map[string]string, err error)
+}
+
+// Load defines the properties and texts that are loaded for the target nodes
+// and the property nodes of edges
+type Load struct {
+	Props     []string
+	Texts     []string
+	EdgeProps []string
+	EdgeTexts []string
+}
+
+func findBatchLoader(tr Transaction) (BatchLoader, bool) {
+	var st interface{} = tr
+	for {
+		if bl, ok := st.(BatchLoader); ok {
+			return bl, true
+		}
+		u, ok := st.(Unwrapper)
+		if !ok {
+			return nil, false
+		}
+		st = u.Unwrap()
+	}
+}
+
+// batch loads the given properties and texts for the nodes that must all belong to the given transaction.
+// Nodes that do not exist are left untouched. If the transaction is no BatchLoader, the nodes are
+// loaded one by one; then the stores can't tell missing nodes apart from other errors, so a node
+// without properties is an error.
+func batch(tr Transaction, nodes []*Node, props, texts []string) error {
+	if len(nodes) == 0 || (len(props) == 0 && len(texts) == 0) {
+		return nil
+	}
+
+	bl, ok := findBatchLoader(tr)
+	if !ok {
+		for _, n := range nodes {
+			if len(props) > 0 {
+				if err := n.LoadProperties(props); err != nil {
+					return err
+				}
+			}
+			if len(texts) > 0 {
+				if err := n.LoadTexts(texts); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	uuids := make([]string, len(nodes))
+	for i, n := range nodes {
+		uuids[i] = n.Id
+	}
+
+	if len(props) > 0 {
+		all, err := bl.GetNodesProperties(uuids, props)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			for k, v := range all[n.Id] {
+				n.props[k] = v
+				n.dirty[k] = false
+			}
+		}
+	}
+
+	if len(texts) > 0 {
+		all, err := bl.GetNodesTexts(uuids, texts)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			for k, v := range all[n.Id] {
+				n.texts[k] = v
+				n.dirty[k] = false
+			}
+		}
+	}
+	return nil
+}
+
+// LoadEdges loads the requested properties and texts of the target nodes and of the property nodes
+// of the given edges with one batched store call per shard.
+// Target nodes that do not exist are left empty, if their store is a BatchLoader (see batch).
+func LoadEdges(edges []*Edge, l Load) error {
+	targets := map[Transaction][]*Node{}
+	propNodes := map[Transaction][]*Node{}
+	var order []Transaction
+	seen := map[Transaction]bool{}
+
+	for _, e := range edges {
+		targets[e.To.Transaction] = append(targets[e.To.Transaction], e.To)
+		if !seen[e.To.Transaction] {
+			seen[e.To.Transaction] = true
+			order = append(order, e.To.Transaction)
+		}
+		if e.Properties == nil {
+			continue
+		}
+		propNodes[e.Properties.Transaction] = append(propNodes[e.Properties.Transaction], e.Properties)
+		if !seen[e.Properties.Transaction] {
+			seen[e.Properties.Transaction] = true
+			order = append(order, e.Properties.Transaction)
+		}
+	}
+
+	for _, tr := range order {
+		if err := batch(tr, targets[tr], l.Props, l.Texts); err != nil {
+			return err
+		}
+		if err := batch(tr, propNodes[tr], l.EdgeProps, l.EdgeTexts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEdgesWith is like GetEdges, but loads the requested properties and texts
+// of the target nodes and the property nodes, see LoadEdges
+func (n *Node) GetEdgesWith(target Transaction, category string, l Load) ([]*Edge, error) {
+	edges, err := n.GetEdges(target, category)
+	if err != nil {
+		return nil, err
+	}
+	return edges, LoadEdges(edges, l)
+}
+
+// GetEdgeWith is like GetEdge, but loads the requested properties and texts
+// of the target node and the property node, see LoadEdges
+func (n *Node) GetEdgeWith(category string, to *Node, l Load) (*Edge, error) {
+	e, err := n.GetEdge(category, to)
+	if err != nil || e == nil {
+		return e, err
+	}
+	return e, LoadEdges([]*Edge{e}, l)
+}
@@ -0,0 +1,147 @@
+package zoom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+// countingStore counts the calls to the store
+type countingStore struct {
+	*memstore.Store
+	single, batched int
+}
+
+func (c *countingStore) GetNodeProperties(uuid string, requested []string) (map[string]interface{}, error) {
+	c.single++
+	return c.Store.GetNodeProperties(uuid, requested)
+}
+
+func (c *countingStore) GetNodesProperties(uuids []string, requested []string) (map[string]map[string]interface{}, error) {
+	c.batched++
+	return c.Store.GetNodesProperties(uuids, requested)
+}
+
+func TestLoadEdges(t *testing.T) {
+	persons := &countingStore{Store: memstore.New("persons")}
+	cities := memstore.New("cities")
+
+	donald := zoom.NewNode(persons, "")
+	donald.SetString("Name", "Donald")
+	if err := donald.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]string{}
+	for _, name := range []string{"Daisy", "Gustav", "Dagobert"} {
+		n := zoom.NewNode(persons, "")
+		n.SetString("Name", name)
+		n.SetText("Bio", "the bio of "+name)
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+		names[n.ID()] = name
+		if err := donald.NewEdge("knows", n, map[string]interface{}{"Since": name + " was born"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	duckburg := zoom.NewNode(cities, "")
+	duckburg.SetString("Name", "Duckburg")
+	if err := duckburg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := donald.NewEdge("knows", duckburg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	persons.single, persons.batched = 0, 0
+
+	edges, err := donald.GetEdgesWith(persons, "knows", zoom.Load{Props: []string{"Name"}, Texts: []string{"Bio"}, EdgeProps: []string{"Since"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edges) != 3 {
+		t.Fatalf("got %d edges, expected 3", len(edges))
+	}
+
+	for _, e := range edges {
+		name := names[e.To.ID()]
+		if got := e.To.GetString("Name"); got != name {
+			t.Errorf("Name = %#v, expected %#v", got, name)
+		}
+		if got := e.To.GetText("Bio"); got != "the bio of "+name {
+			t.Errorf("Bio = %#v, expected %#v", got, "the bio of "+name)
+		}
+		if got := e.Properties.GetString("Since"); got != name+" was born" {
+			t.Errorf("Since = %#v, expected %#v", got, name+" was born")
+		}
+	}
+
+	if persons.single != 0 || persons.batched != 2 {
+		t.Errorf("%d single and %d batched calls, expected 0 and 2", persons.single, persons.batched)
+	}
+
+	// the targets in another shard are loaded from their transaction
+	edges, err = zoom.NewResolver(persons, cities).Edges(donald, "knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zoom.LoadEdges(edges, zoom.Load{Props: []string{"Name"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range edges {
+		if e.To.GetString("Name") == "" {
+			t.Errorf("Name of %s has not been loaded", e.To.ID())
+		}
+	}
+
+	e, err := donald.GetEdgeWith("knows", duckburg, zoom.Load{Props: []string{"Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := e.To.GetString("Name"); got != "Duckburg" {
+		t.Errorf("Name = %#v, expected %#v", got, "Duckburg")
+	}
+}
+
+// failingStore is no BatchLoader and fails to load the properties of one node
+type failingStore struct {
+	zoom.Store
+	fail string
+}
+
+func (f *failingStore) GetNodeProperties(uuid string, requested []string) (map[string]interface{}, error) {
+	if uuid == f.fail {
+		return nil, errors.New("storage failure")
+	}
+	return f.Store.GetNodeProperties(uuid, requested)
+}
+
+func TestLoadEdgesError(t *testing.T) {
+	st := &failingStore{Store: memstore.New("shard1")}
+
+	donald := zoom.NewNode(st, "")
+	daisy := zoom.NewNode(st, "")
+	for _, n := range []*zoom.Node{donald, daisy} {
+		n.SetString("Name", "duck")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := donald.NewEdge("knows", daisy, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st.fail = daisy.ID()
+	if _, err := donald.GetEdgesWith(st, "knows", zoom.Load{Props: []string{"Name"}}); err == nil {
+		t.Errorf("expected the error of the store")
+	}
+}
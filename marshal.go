@@ -0,0 +1,380 @@
+package zoom
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+	struct mapping
+
+	Marshal and Unmarshal copy the tagged fields of a struct to and from a node, e.g.
+
+		type Person struct {
+			FirstName string    `zoom:"FirstName"`
+			Age       int64     `zoom:"Age"`
+			Tags      []string  `zoom:"Tags"`
+			Born      time.Time `zoom:"Born"`
+			Bio       string    `zoom:"Bio,text"`
+		}
+
+	Fields without zoom tag or with the tag "-" are ignored.
+	Fields with the option text are saved as texts and must be strings,
+	the other fields are saved as properties and may be of the types
+	string, bool, int*, uint*, float*, time.Time, *time.Time and slices of string, bool, int64, float64 and time.Time.
+	Unsigned integers are saved as int64, so values above math.MaxInt64 can't be marshalled.
+*/
+
+// MissingError is returned by Unmarshal, if some of the requested properties or texts
+// do not exist for the node. The struct fields of the missing properties and texts are left untouched.
+type MissingError struct {
+	NodeID     string
+	Properties []string
+	Texts      []string
+}
+
+func (m *MissingError) Error() string {
+	var missing []string
+	if len(m.Properties) > 0 {
+		missing = append(missing, "properties "+strings.Join(m.Properties, ", "))
+	}
+	if len(m.Texts) > 0 {
+		missing = append(missing, "texts "+strings.Join(m.Texts, ", "))
+	}
+	return fmt.Sprintf("node %s misses %s", m.NodeID, strings.Join(missing, " and "))
+}
+
+type structField struct {
+	index  int
+	name   string
+	isText bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func structFields(v interface{}) (rv reflect.Value, fields []structField, err error) {
+	rv = reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return rv, nil, fmt.Errorf("%T is not a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag := f.Tag.Get("zoom")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if f.PkgPath != "" {
+			return rv, nil, fmt.Errorf("field %s of %s is tagged but not exported", f.Name, rt)
+		}
+
+		parts := strings.Split(tag, ",")
+		sf := structField{index: i, name: parts[0]}
+
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "text":
+				sf.isText = true
+			default:
+				return rv, nil, fmt.Errorf("unknown option %#v for field %s of %s", opt, f.Name, rt)
+			}
+		}
+
+		if sf.name == "" {
+			sf.name = f.Name
+		}
+
+		if sf.isText && f.Type.Kind() != reflect.String {
+			return rv, nil, fmt.Errorf("text field %s of %s must be a string", f.Name, rt)
+		}
+
+		fields = append(fields, sf)
+	}
+	return
+}
+
+// Marshal sets the properties and texts of the node to the values of the tagged fields
+// of the struct that v points to. The node still has to be saved.
+func Marshal(n *Node, v interface{}) error {
+	rv, fields, err := structFields(v)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		if f.isText {
+			n.SetText(f.name, fv.String())
+			continue
+		}
+
+		if err := n.setValue(f.name, fv); err != nil {
+			return fmt.Errorf("can't marshal field %s: %s", rv.Type().Field(f.index).Name, err)
+		}
+	}
+	return nil
+}
+
+func (n *Node) setValue(prop string, fv reflect.Value) error {
+	switch fv.Kind() {
+	case reflect.String:
+		return n.SetString(prop, fv.String())
+	case reflect.Bool:
+		n.SetBool(prop, fv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n.SetInt(prop, fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// saved as int64 like the other integers
+		if fv.Uint() > math.MaxInt64 {
+			return fmt.Errorf("%d overflows int64", fv.Uint())
+		}
+		n.SetInt(prop, int64(fv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		n.SetFloat(prop, fv.Float())
+	case reflect.Struct:
+		if fv.Type() != timeType {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		t := fv.Interface().(time.Time)
+		n.SetTime(prop, &t)
+	case reflect.Ptr:
+		if fv.Type().Elem() != timeType {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		n.SetTime(prop, fv.Interface().(*time.Time))
+	case reflect.Slice:
+		switch vals := fv.Interface().(type) {
+		case []string:
+			return n.SetStrings(prop, vals...)
+		case []bool:
+			n.SetBools(prop, vals...)
+		case []int64:
+			n.SetInts(prop, vals...)
+		case []float64:
+			n.SetFloats(prop, vals...)
+		case []time.Time:
+			n.SetTimes(prop, vals...)
+		default:
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// Unmarshal loads the properties and texts of the tagged fields of the struct that v points to
+// from the transaction of the node and sets the fields to their values.
+// If some of the properties or texts do not exist, a *MissingError is returned
+// after all existing values have been set.
+func Unmarshal(n *Node, v interface{}) error {
+	rv, fields, err := structFields(v)
+	if err != nil {
+		return err
+	}
+
+	var props, texts []string
+	for _, f := range fields {
+		if f.isText {
+			texts = append(texts, f.name)
+		} else {
+			props = append(props, f.name)
+		}
+	}
+
+	// missing values are determined by what the store returns, not by the values already set on the node
+	loadedProps, err := n.loadProperties(props)
+	if err != nil {
+		return err
+	}
+
+	loadedTexts, err := n.loadTexts(texts)
+	if err != nil {
+		return err
+	}
+
+	missing := &MissingError{NodeID: n.ID()}
+
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		if f.isText {
+			text, has := loadedTexts[f.name]
+			if !has {
+				missing.Texts = append(missing.Texts, f.name)
+				continue
+			}
+			fv.SetString(text)
+			continue
+		}
+
+		val, has := loadedProps[f.name]
+		if !has {
+			missing.Properties = append(missing.Properties, f.name)
+			continue
+		}
+
+		if err := setField(fv, val); err != nil {
+			return fmt.Errorf("can't unmarshal property %s to field %s: %s", f.name, rv.Type().Field(f.index).Name, err)
+		}
+	}
+
+	if len(missing.Properties) > 0 || len(missing.Texts) > 0 {
+		return missing
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, val interface{}) error {
+	if val == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	// property files without types have times saved as strings
+	if s, isString := val.(string); isString && (fv.Type() == timeType || fv.Type() == reflect.PtrTo(timeType)) {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		val = t
+	}
+
+	if t, isTime := val.(time.Time); isTime && fv.Kind() == reflect.Ptr {
+		val = &t
+	}
+
+	if t, isTimePtr := val.(*time.Time); isTimePtr && fv.Kind() == reflect.Struct && t != nil {
+		val = *t
+	}
+
+	rval := reflect.ValueOf(val)
+
+	switch {
+	case rval.Type().AssignableTo(fv.Type()):
+		fv.Set(rval)
+	case isNumber(rval.Kind()) && isNumber(fv.Kind()):
+		// ints of other sizes and numbers from property files without types
+		conv, err := convertNumber(rval, fv.Type())
+		if err != nil {
+			return err
+		}
+		fv.Set(conv)
+	case rval.Kind() == reflect.Slice && fv.Kind() == reflect.Slice:
+		// slices from property files without types
+		s := reflect.MakeSlice(fv.Type(), rval.Len(), rval.Len())
+		for i := 0; i < rval.Len(); i++ {
+			if err := setField(s.Index(i), rval.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+	default:
+		return fmt.Errorf("can't assign %T to %s", val, fv.Type())
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	return isInt(k) || isUint(k) || isFloat(k)
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// convertNumber converts the number to the given type, if it can be represented exactly (floats to
+// float32 may lose precision, but must not overflow)
+func convertNumber(rval reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	k := rval.Kind()
+	target := reflect.New(typ).Elem()
+	overflow := fmt.Errorf("%v overflows %s", rval.Interface(), typ)
+
+	switch {
+	case isFloat(typ.Kind()):
+		var f float64
+		switch {
+		case isInt(k):
+			f = float64(rval.Int())
+		case isUint(k):
+			f = float64(rval.Uint())
+		default:
+			f = rval.Float()
+		}
+		if target.OverflowFloat(f) {
+			return target, overflow
+		}
+		target.SetFloat(f)
+		return target, nil
+
+	case isFloat(k):
+		f := rval.Float()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return target, fmt.Errorf("%v is not an integer", f)
+		}
+		// 2^63 and 2^64 are exact floats
+		if isInt(typ.Kind()) {
+			if f < -(1<<63) || f >= 1<<63 || target.OverflowInt(int64(f)) {
+				return target, overflow
+			}
+			target.SetInt(int64(f))
+			return target, nil
+		}
+		if f < 0 || f >= 1<<64 || target.OverflowUint(uint64(f)) {
+			return target, overflow
+		}
+		target.SetUint(uint64(f))
+		return target, nil
+
+	case isInt(k):
+		i := rval.Int()
+		if isInt(typ.Kind()) {
+			if target.OverflowInt(i) {
+				return target, overflow
+			}
+			target.SetInt(i)
+			return target, nil
+		}
+		if i < 0 || target.OverflowUint(uint64(i)) {
+			return target, overflow
+		}
+		target.SetUint(uint64(i))
+		return target, nil
+
+	default: // uint
+		u := rval.Uint()
+		if isUint(typ.Kind()) {
+			if target.OverflowUint(u) {
+				return target, overflow
+			}
+			target.SetUint(u)
+			return target, nil
+		}
+		if u > math.MaxInt64 || target.OverflowInt(int64(u)) {
+			return target, overflow
+		}
+		target.SetInt(int64(u))
+		return target, nil
+	}
+}
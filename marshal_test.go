@@ -0,0 +1,225 @@
+package zoom_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+type person struct {
+	FirstName string    `zoom:"FirstName"`
+	Age       int       `zoom:"Age"`
+	Height    float64   `zoom:"Height"`
+	Married   bool      `zoom:"Married"`
+	Born      time.Time `zoom:"Born"`
+	Tags      []string  `zoom:"Tags"`
+	Bio       string    `zoom:"Bio,text"`
+	Ignored   string
+}
+
+func TestMarshal(t *testing.T) {
+	store := memstore.New("shard1")
+
+	in := person{
+		FirstName: "Donald",
+		Age:       44,
+		Height:    1.2,
+		Married:   false,
+		Born:      time.Date(1934, 6, 9, 0, 0, 0, 0, time.UTC),
+		Tags:      []string{"duck", "sailor"},
+		Bio:       "Donald Fauntleroy Duck is a cartoon character",
+		Ignored:   "ignored",
+	}
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "marshal"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		if err := zoom.Marshal(n, &in); err != nil {
+			return err
+		}
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out person
+	if err := zoom.Unmarshal(zoom.NewNode(store, id), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.Born.Equal(in.Born) {
+		t.Errorf("Born = %v, expected %v", out.Born, in.Born)
+	}
+	out.Born = in.Born
+	in.Ignored = ""
+
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal() = %#v, expected %#v", out, in)
+	}
+}
+
+func TestUnmarshalMissing(t *testing.T) {
+	store := memstore.New("shard1")
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		n.SetString("FirstName", "Daisy")
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out person
+	err = zoom.Unmarshal(zoom.NewNode(store, id), &out)
+
+	missing, ok := err.(*zoom.MissingError)
+	if !ok {
+		t.Fatalf("expected *zoom.MissingError, got %#v", err)
+	}
+
+	if expected := []string{"Age", "Height", "Married", "Born", "Tags"}; !reflect.DeepEqual(missing.Properties, expected) {
+		t.Errorf("missing properties = %#v, expected %#v", missing.Properties, expected)
+	}
+
+	if expected := []string{"Bio"}; !reflect.DeepEqual(missing.Texts, expected) {
+		t.Errorf("missing texts = %#v, expected %#v", missing.Texts, expected)
+	}
+
+	if out.FirstName != "Daisy" {
+		t.Errorf("FirstName = %#v, expected %#v", out.FirstName, "Daisy")
+	}
+}
+
+func TestUnmarshalMissingIgnoresNodeValues(t *testing.T) {
+	store := memstore.New("shard1")
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		n.SetString("FirstName", "Daisy")
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := zoom.NewNode(store, id)
+	n.SetInt("Age", 42)
+	n.SetText("Bio", "not saved")
+
+	var out person
+	missing, ok := zoom.Unmarshal(n, &out).(*zoom.MissingError)
+	if !ok {
+		t.Fatalf("expected *zoom.MissingError")
+	}
+
+	if expected := []string{"Age", "Height", "Married", "Born", "Tags"}; !reflect.DeepEqual(missing.Properties, expected) {
+		t.Errorf("missing properties = %#v, expected %#v", missing.Properties, expected)
+	}
+
+	if expected := []string{"Bio"}; !reflect.DeepEqual(missing.Texts, expected) {
+		t.Errorf("missing texts = %#v, expected %#v", missing.Texts, expected)
+	}
+}
+
+func TestUnmarshalNumberOverflow(t *testing.T) {
+	tests := []struct {
+		val   interface{}
+		field interface{}
+		ok    bool
+	}{
+		{int64(127), &struct {
+			V int8 `zoom:"V"`
+		}{}, true},
+		{int64(128), &struct {
+			V int8 `zoom:"V"`
+		}{}, false},
+		{int64(-1), &struct {
+			V uint `zoom:"V"`
+		}{}, false},
+		{int64(7), &struct {
+			V uint16 `zoom:"V"`
+		}{}, true},
+		{float64(2.5), &struct {
+			V int `zoom:"V"`
+		}{}, false},
+		{float64(3), &struct {
+			V int `zoom:"V"`
+		}{}, true},
+		{float64(1e300), &struct {
+			V int64 `zoom:"V"`
+		}{}, false},
+		{float64(1e300), &struct {
+			V float32 `zoom:"V"`
+		}{}, false},
+	}
+
+	for _, test := range tests {
+		store := memstore.New("shard1")
+		var id string
+		err := store.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+			n := zoom.NewNode(tr, "")
+			id = n.ID()
+			return tr.SaveNodeProperties(id, map[string]interface{}{"V": test.val})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = zoom.Unmarshal(zoom.NewNode(store, id), test.field)
+		if (err == nil) != test.ok {
+			t.Errorf("Unmarshal(%v) into %T returned error %v, expected ok: %v", test.val, test.field, err, test.ok)
+		}
+	}
+}
+
+func TestMarshalUint(t *testing.T) {
+	store := memstore.New("shard1")
+
+	type counter struct {
+		Count uint64 `zoom:"Count"`
+		Small uint8  `zoom:"Small"`
+	}
+
+	var id string
+	err := store.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		if err := zoom.Marshal(n, &counter{Count: math.MaxInt64, Small: 7}); err != nil {
+			return err
+		}
+		return n.Save()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got counter
+	if err := zoom.Unmarshal(zoom.NewNode(store, id), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (counter{Count: math.MaxInt64, Small: 7}); got != expected {
+		t.Errorf("Unmarshal() = %#v, expected %#v", got, expected)
+	}
+
+	if err := zoom.Marshal(zoom.NewNode(store, ""), &counter{Count: math.MaxInt64 + 1}); err == nil {
+		t.Errorf("Marshal() of %d should return an error", uint64(math.MaxInt64+1))
+	}
+}
@@ -0,0 +1,476 @@
+package memstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/codec"
+)
+
+/*
+memstore keeps the data of a shard in memory, using the same file layout as gitstore.
+there are two sets of files: the committed ones (head) and the staged ones (index).
+all changes go to the index, reads are also served from the index (like in gitstore),
+Commit copies the index to head and Rollback copies head back to the index.
+*/
+
+// Store is an in memory zoom.Store. It is not safe for concurrent use.
+type Store struct {
+	shard string
+	codec codec.Codec
+	head  map[string][]byte
+	index map[string][]byte
+
+	// indexes are saved outside of the transaction (like in gitstore)
+	indexes map[string][]byte
+}
+
+var _ zoom.Store = &Store{}
+
+// New returns an empty Store for the given shard that writes node and edge files with the JSON codec
+func New(shard string) *Store {
+	return NewWithCodec(shard, codec.JSON)
+}
+
+// NewWithCodec returns an empty Store for the given shard that writes node and edge files with the given codec
+func NewWithCodec(shard string, c codec.Codec) *Store {
+	return &Store{
+		shard:   shard,
+		codec:   c,
+		head:    map[string][]byte{},
+		index:   map[string][]byte{},
+		indexes: map[string][]byte{},
+	}
+}
+
+// Transaction runs the given action via zoom.NewTransaction on the store
+func (s *Store) Transaction(msg zoom.CommitMessage, action func(zoom.Transaction) error) error {
+	return zoom.NewTransaction(s, msg, action)
+}
+
+func copyFiles(files map[string][]byte) map[string][]byte {
+	c := make(map[string][]byte, len(files))
+	for k, v := range files {
+		c[k] = v
+	}
+	return c
+}
+
+func (s *Store) isFileKnown(path string) bool {
+	_, has := s.index[path]
+	return has
+}
+
+// lsFiles returns the sorted paths of the staged files for which match returns true
+func (s *Store) lsFiles(match func(path string) bool) []string {
+	var files []string
+	for p := range s.index {
+		if match(p) {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (s *Store) save(path string, data interface{}) error {
+	var buf bytes.Buffer
+	err := codec.Write(&buf, s.codec, data)
+	if err != nil {
+		return err
+	}
+	s.index[path] = buf.Bytes()
+	return nil
+}
+
+func (s *Store) load(path string, data interface{}) error {
+	b, has := s.index[path]
+	if !has {
+		return fmt.Errorf("file %#v does not exist", path)
+	}
+	_, err := codec.Read(bytes.NewReader(b), data)
+	return err
+}
+
+func (s *Store) edgePath(category string, uuid string) string {
+	return fmt.Sprintf("refs/%s/%s/%s/%s", category, s.shard, uuid[:2], uuid[2:])
+}
+
+func (s *Store) incomingEdgePath(category string, uuid string) string {
+	return fmt.Sprintf("backrefs/%s/%s/%s/%s", category, s.shard, uuid[:2], uuid[2:])
+}
+
+func (s *Store) propPath(uuid string) string {
+	return fmt.Sprintf("node/%s/%s/%s", s.shard, uuid[:2], uuid[2:])
+}
+
+func (s *Store) textPath(uuid string, key string) string {
+	return fmt.Sprintf("text/%s/%s/%s/%s", s.shard, uuid[:2], uuid[2:], key)
+}
+
+func (s *Store) blobPath(uuid string, key string) string {
+	return fmt.Sprintf("blob/%s/%s/%s/%s", s.shard, uuid[:2], uuid[2:], key)
+}
+
+// map relname => nodeUuid, only the texts that have a key set are going to be changed
+func (s *Store) SaveNodeTexts(uuid string, texts map[string]string) error {
+	for textPath, text := range texts {
+		s.index[s.textPath(uuid, textPath)] = []byte(text)
+	}
+	return nil
+}
+
+func (s *Store) GetNodeTexts(uuid string, requestedTexts []string) (texts map[string]string, err error) {
+	texts = map[string]string{}
+	for _, text := range requestedTexts {
+		b, has := s.index[s.textPath(uuid, text)]
+		if has {
+			texts[text] = string(b)
+		}
+	}
+	return
+}
+
+// map blobname => content, only the blobs that have a key set are going to be changed
+// the blobs are part of the transaction (like in gitstore)
+func (s *Store) SaveNodeBlobs(uuid string, blobs map[string]io.Reader) error {
+	for blobPath, blob := range blobs {
+		b, err := ioutil.ReadAll(blob)
+		if err != nil {
+			return err
+		}
+		s.index[s.blobPath(uuid, blobPath)] = b
+	}
+	return nil
+}
+
+// GetNodeBlobs calls fn for each existing blob in requestedBlobs
+func (s *Store) GetNodeBlobs(uuid string, requestedBlobs []string, fn func(string, io.Reader) error) error {
+	for _, blob := range requestedBlobs {
+		b, has := s.index[s.blobPath(uuid, blob)]
+		if !has {
+			continue
+		}
+		if err := fn(blob, bytes.NewReader(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) SaveEdges(category, uuid string, edges map[string]string) error {
+	return s.save(s.edgePath(category, uuid), edges)
+}
+
+// RemoveEdges also removes the properties node of an edge and the edges from the incoming edges of their targets in the shard
+// Is the edges file is already removed, no error should be returned
+func (s *Store) RemoveEdges(category, uuid string) error {
+	edges, err := s.GetEdges(category, uuid)
+	if err != nil {
+		return err
+	}
+
+	for _, val := range edges {
+		propID, _, _, err := zoom.ParseEdgeValue(val)
+		if err != nil {
+			return err
+		}
+		if propID == "" {
+			continue
+		}
+		if err := s.RemoveNode(propID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.removeIncoming(category, uuid, edges); err != nil {
+		return err
+	}
+
+	delete(s.index, s.edgePath(category, uuid))
+	return nil
+}
+
+// removeIncoming removes the edges of the node from the incoming edges of their targets in the shard.
+// the incoming edges of targets in other shards are part of their stores (see zoom.Store.RemoveEdges)
+func (s *Store) removeIncoming(category, uuid string, edges map[string]string) error {
+	for key := range edges {
+		shard, to, id, err := zoom.SplitEdgeKey(key)
+		if err != nil {
+			return err
+		}
+		if shard != s.shard {
+			continue
+		}
+
+		incoming, err := s.GetIncomingEdges(category, to)
+		if err != nil {
+			return err
+		}
+		delete(incoming, zoom.EdgeKey(s.shard+"-"+uuid, id))
+		if len(incoming) == 0 {
+			err = s.RemoveIncomingEdges(category, to)
+		} else {
+			err = s.SaveIncomingEdges(category, to, incoming)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAllIncoming removes all edges of the node from the incoming edges of their targets in the shard
+func (s *Store) removeAllIncoming(uuid string) error {
+	categories, err := s.GetEdgeCategories(uuid)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		edges, err := s.GetEdges(category, uuid)
+		if err != nil {
+			return err
+		}
+		if err := s.removeIncoming(category, uuid, edges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// if there is no edge file for the given category, no error is returned, but empty  edges map
+func (s *Store) GetEdges(category, uuid string) (edges map[string]string, err error) {
+	path := s.edgePath(category, uuid)
+	edges = map[string]string{}
+
+	if !s.isFileKnown(path) {
+		return edges, nil
+	}
+	err = s.load(path, &edges)
+	return edges, err
+}
+
+// GetEdgeCategories returns the sorted categories of the edge files of the node
+func (s *Store) GetEdgeCategories(uuid string) (categories []string, err error) {
+	suffix := fmt.Sprintf("/%s/%s/%s", s.shard, uuid[:2], uuid[2:])
+	files := s.lsFiles(func(p string) bool {
+		return strings.HasPrefix(p, "refs/") && strings.HasSuffix(p, suffix)
+	})
+
+	categories = []string{}
+	for _, file := range files {
+		categories = append(categories, strings.TrimSuffix(strings.TrimPrefix(file, "refs/"), suffix))
+	}
+	sort.Strings(categories)
+	return
+}
+
+func (s *Store) SaveIncomingEdges(category, uuid string, edges map[string]string) error {
+	return s.save(s.incomingEdgePath(category, uuid), edges)
+}
+
+// RemoveIncomingEdges does not remove the property nodes of the edges
+func (s *Store) RemoveIncomingEdges(category, uuid string) error {
+	delete(s.index, s.incomingEdgePath(category, uuid))
+	return nil
+}
+
+// if there is no incoming edge file for the given category, no error is returned, but empty  edges map
+func (s *Store) GetIncomingEdges(category, uuid string) (edges map[string]string, err error) {
+	path := s.incomingEdgePath(category, uuid)
+	edges = map[string]string{}
+
+	if !s.isFileKnown(path) {
+		return edges, nil
+	}
+	err = s.load(path, &edges)
+	return edges, err
+}
+
+// only the props that have a key set are going to be changed
+func (s *Store) SaveNodeProperties(uuid string, props map[string]interface{}) error {
+	path := s.propPath(uuid)
+
+	if s.isFileKnown(path) {
+		orig, err := s.loadProps(path)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range props {
+			if v == nil {
+				delete(orig, k)
+			} else {
+				orig[k] = v
+			}
+		}
+		props = orig
+	}
+
+	return s.saveProps(path, props)
+}
+
+// saveProps saves the properties with their types (same as in gitstore)
+func (s *Store) saveProps(path string, props map[string]interface{}) error {
+	data, err := codec.EncodeProperties(props)
+	if err != nil {
+		return err
+	}
+	return s.save(path, data)
+}
+
+func (s *Store) loadProps(path string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := s.load(path, &data)
+	if err != nil {
+		return nil, err
+	}
+	return codec.DecodeProperties(data)
+}
+
+// RemoveNode removes the properties, texts, blobs, edge and incoming edge files of the node
+// and the edges of the node from the incoming edges of their targets in the shard.
+// the property nodes of the edges are not removed (same as in gitstore)
+// if any file does not exist, no error is returned
+func (s *Store) RemoveNode(uuid string) error {
+	if err := s.removeAllIncoming(uuid); err != nil {
+		return err
+	}
+
+	edgeSuffix := fmt.Sprintf("/%s/%s/%s", s.shard, uuid[:2], uuid[2:])
+	textPrefix := fmt.Sprintf("text/%s/%s/%s/", s.shard, uuid[:2], uuid[2:])
+	blobPrefix := fmt.Sprintf("blob/%s/%s/%s/", s.shard, uuid[:2], uuid[2:])
+
+	files := s.lsFiles(func(p string) bool {
+		return ((strings.HasPrefix(p, "refs/") || strings.HasPrefix(p, "backrefs/")) && strings.HasSuffix(p, edgeSuffix)) ||
+			strings.HasPrefix(p, textPrefix) || strings.HasPrefix(p, blobPrefix)
+	})
+
+	for _, file := range files {
+		delete(s.index, file)
+	}
+
+	delete(s.index, s.propPath(uuid))
+	return nil
+}
+
+// ScanNodes calls fn for the uuids of the nodes with the given prefix in order
+func (s *Store) ScanNodes(prefix string, fn func(uuid string) error) error {
+	dir := fmt.Sprintf("node/%s/", s.shard)
+	files := s.lsFiles(func(p string) bool {
+		return strings.HasPrefix(p, dir)
+	})
+
+	for _, file := range files {
+		uuid := strings.Replace(strings.TrimPrefix(file, dir), "/", "", 1)
+		if !strings.HasPrefix(uuid, prefix) {
+			continue
+		}
+		if err := fn(uuid); err != nil {
+			if err == zoom.StopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// only the properties that exist make it into the returned map
+// it is no error if a requested property does not exist for a node
+// if the node properties file is not there, an error is returned (same as in gitstore)
+func (s *Store) GetNodeProperties(uuid string, requestedProps []string) (props map[string]interface{}, err error) {
+	orig, err := s.loadProps(s.propPath(uuid))
+	if err != nil {
+		return nil, err
+	}
+	props = map[string]interface{}{}
+
+	for _, req := range requestedProps {
+		v, has := orig[req]
+		if has {
+			props[req] = v
+		}
+	}
+	return
+}
+
+// GetNodesProperties returns the requested properties of the existing nodes
+func (s *Store) GetNodesProperties(uuids []string, requestedProps []string) (props map[string]map[string]interface{}, err error) {
+	props = map[string]map[string]interface{}{}
+	for _, uuid := range uuids {
+		if !s.isFileKnown(s.propPath(uuid)) {
+			continue
+		}
+		p, err := s.GetNodeProperties(uuid, requestedProps)
+		if err != nil {
+			return nil, err
+		}
+		props[uuid] = p
+	}
+	return
+}
+
+// GetNodesTexts returns the requested texts of the nodes
+func (s *Store) GetNodesTexts(uuids []string, requestedTexts []string) (texts map[string]map[string]string, err error) {
+	texts = map[string]map[string]string{}
+	for _, uuid := range uuids {
+		t, err := s.GetNodeTexts(uuid, requestedTexts)
+		if err != nil {
+			return nil, err
+		}
+		texts[uuid] = t
+	}
+	return
+}
+
+// Commit makes the staged changes permanent
+func (s *Store) Commit(msg zoom.CommitMessage) error {
+	s.head = copyFiles(s.index)
+	return nil
+}
+
+// Rollback throws away all changes since the last commit
+func (s *Store) Rollback() error {
+	s.index = copyFiles(s.head)
+	return nil
+}
+
+func (s *Store) Shard() string {
+	return s.shard
+}
+
+// SaveIndex saves the index data, it is not part of the transaction
+func (s *Store) SaveIndex(indexpath string, rd io.Reader) error {
+	b, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	s.indexes[indexpath] = b
+	return nil
+}
+
+// GetIndex calls fn with the index data, if the index exists
+func (s *Store) GetIndex(indexpath string, fn func(io.Reader) error) error {
+	b, has := s.indexes[indexpath]
+	if !has {
+		return nil
+	}
+	return fn(bytes.NewReader(b))
+}
+
+// RenameIndex renames the index data from to the index to, it is not part of the transaction
+func (s *Store) RenameIndex(from, to string) error {
+	b, has := s.indexes[from]
+	if !has {
+		return fmt.Errorf("index %#v does not exist", from)
+	}
+	s.indexes[to] = b
+	delete(s.indexes, from)
+	return nil
+}
@@ -0,0 +1,162 @@
+package memstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/codec"
+	"github.com/metakeule/zoom/storetest"
+)
+
+func TestCommit(t *testing.T) {
+	st := New("shard1")
+	var id string
+
+	err := st.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("FirstName", "Donald")
+		n.SetText("Bio", "lives in Duckburg")
+		id = n.ID()
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := zoom.NewNode(st, id)
+
+	if err := n.LoadProperties([]string{"FirstName"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.LoadTexts([]string{"Bio"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := n.GetString("FirstName"), "Donald"; got != want {
+		t.Errorf("FirstName = %#v, expected %#v", got, want)
+	}
+
+	if got, want := n.GetText("Bio"), "lives in Duckburg"; got != want {
+		t.Errorf("Bio = %#v, expected %#v", got, want)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	st := New("shard1")
+	var id string
+
+	err := st.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("FirstName", "Donald")
+		id = n.ID()
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errFailed := errors.New("failed")
+
+	err = st.Transaction(zoom.CommitMessage{Command: "change"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.SetString("FirstName", "Daisy")
+		if err := n.Save(); err != nil {
+			return err
+		}
+		n2 := zoom.NewNode(tr, "")
+		n2.SetString("FirstName", "Mickey")
+		if err := n2.Save(); err != nil {
+			return err
+		}
+		return errFailed
+	})
+
+	if err != errFailed {
+		t.Fatalf("expected error %v, got %v", errFailed, err)
+	}
+
+	props, err := st.GetNodeProperties(id, []string{"FirstName"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := props["FirstName"], "Donald"; got != want {
+		t.Errorf("FirstName = %#v, expected %#v", got, want)
+	}
+
+	if got, want := len(st.index), 1; got != want {
+		t.Errorf("len(index) = %d, expected %d", got, want)
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	st := New("shard1")
+	var from, to *zoom.Node
+
+	err := st.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		from = zoom.NewNode(tr, "")
+		from.SetString("Name", "from")
+		from.SetText("Bio", "the source")
+		to = zoom.NewNode(tr, "")
+		to.SetString("Name", "to")
+		if err := from.Save(); err != nil {
+			return err
+		}
+		if err := to.Save(); err != nil {
+			return err
+		}
+		return from.NewEdge("knows", to, nil)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.Transaction(zoom.CommitMessage{Command: "remove"}, func(tr zoom.Transaction) error {
+		return tr.RemoveNode(from.ID())
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.GetNodeProperties(from.ID(), []string{"Name"}); err == nil {
+		t.Errorf("expected error when loading properties of removed node")
+	}
+
+	texts, err := st.GetNodeTexts(from.ID(), []string{"Bio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) != 0 {
+		t.Errorf("texts of removed node should be gone, got %#v", texts)
+	}
+
+	edges, err := st.GetEdges("knows", from.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edges) != 0 {
+		t.Errorf("edges of removed node should be gone, got %#v", edges)
+	}
+}
+
+func TestStoreSuite(t *testing.T) {
+	storetest.Run(t, func() zoom.Store { return New("shard1") })
+}
+
+func TestStoreSuiteCodecs(t *testing.T) {
+	for _, c := range []codec.Codec{codec.MsgPack, codec.CBOR} {
+		c := c
+		t.Run(c.Name(), func(t *testing.T) {
+			storetest.Run(t, func() zoom.Store { return NewWithCodec("shard1", c) })
+		})
+	}
+}
@@ -3,6 +3,7 @@ package zoom
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,53 +18,81 @@ type Node struct {
 	texts       map[string]string      // saved in each file for a text (text is string lenghth > 255) texts are always UTF-8, \n
 	blobs       map[string]io.Reader   // saved outside the repo inside the working dir (will be synced via rsync), blobpath must begin with mimetype
 	dirty       map[string]bool
+
+	// isNew is true for nodes with a generated id until their properties are saved
+	isNew bool
+
+	// schema caches whether the node has a schema, so that Save does not look it up every time
+	schema schemaState
 }
 
 func (n *Node) Properties() map[string]interface{} {
 	return n.props
 }
 
+// Property lets p get its value from the properties of the node
+func (n *Node) Property(p Property) bool {
+	return p.Get(n.props)
+}
+
 func (n *Node) Shard() string {
 	return n.Transaction.Shard()
 }
 
 func (n *Node) LoadProperties(requestedProps []string) (err error) {
+	_, err = n.loadProperties(requestedProps)
+	return
+}
+
+// loadProperties loads the requested properties into the node and returns the properties found in the store
+func (n *Node) loadProperties(requestedProps []string) (map[string]interface{}, error) {
 	// fmt.Println("loading properties")
-	if len(requestedProps) > 0 {
-		props, err := n.Transaction.GetNodeProperties(n.Id, requestedProps)
+	if len(requestedProps) == 0 {
+		return map[string]interface{}{}, nil
+	}
 
-		if err != nil {
-			return err
-		}
+	props, err := n.Transaction.GetNodeProperties(n.Id, requestedProps)
 
-		for k, v := range props {
-			n.props[k] = v
-			n.dirty[k] = false
-		}
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	for k, v := range props {
+		n.props[k] = v
+		n.dirty[k] = false
+	}
+	return props, nil
 }
 
 func (n *Node) LoadTexts(requestedTexts []string) (err error) {
+	_, err = n.loadTexts(requestedTexts)
+	return
+}
+
+// loadTexts loads the requested texts into the node and returns the texts found in the store
+func (n *Node) loadTexts(requestedTexts []string) (map[string]string, error) {
 	// fmt.Println("loading texts")
-	if len(requestedTexts) > 0 {
-		texts, err := n.Transaction.GetNodeTexts(n.Id, requestedTexts)
+	if len(requestedTexts) == 0 {
+		return map[string]string{}, nil
+	}
 
-		// fmt.Printf("texts: %#v\n", texts)
+	texts, err := n.Transaction.GetNodeTexts(n.Id, requestedTexts)
 
-		if err != nil {
-			return err
-		}
+	// fmt.Printf("texts: %#v\n", texts)
 
-		for k, v := range texts {
-			n.texts[k] = v
-			n.dirty[k] = false
-		}
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	for k, v := range texts {
+		n.texts[k] = v
+		n.dirty[k] = false
+	}
+	return texts, nil
 }
 
-/*
+// LoadBlobs calls fn for each of the requested blobs that exists.
+// the reader passed to fn is only valid during the call, blobs are not kept inside the node
 func (n *Node) LoadBlobs(requestedBlobs []string, fn func(string, io.Reader) error) (err error) {
 	if len(requestedBlobs) > 0 {
 		err := n.Transaction.GetNodeBlobs(n.Id, requestedBlobs, fn)
@@ -73,7 +102,6 @@ func (n *Node) LoadBlobs(requestedBlobs []string, fn func(string, io.Reader) err
 	}
 	return nil
 }
-*/
 
 func SplitID(id string) (shard, uuid string, err error) {
 	pos := strings.Index(id, "-")
@@ -109,15 +137,18 @@ func NewNode(tr Transaction, id string) *Node {
 	if tr == nil {
 		panic("transaction may not be nil")
 	}
-	if id == "" {
-		id = uuid.NewV4().String()
-	}
-
 	n := &Node{
 		Transaction: tr,
 		Id:          id,
 	}
 
+	if id == "" {
+		// a new node has no schema until SetSchema is called
+		n.Id = uuid.NewV4().String()
+		n.isNew = true
+		n.schema = schemaNone
+	}
+
 	n.Reset()
 	return n
 
@@ -145,33 +176,81 @@ func (n *Node) GetText(text string) string    { return n.texts[text] }
 func (n *Node) GetBool(prop string) bool      { return n.props[prop].(bool) }
 
 /*
+	multi-valued properties
+
+	GetBools, GetInts, GetFloats, GetStrings and GetTimes return nil, if the property is not set.
+	SetBools, SetInts, SetFloats, SetStrings and SetTimes without values set the property to an
+	empty slice, which is saved and loaded as an empty slice (and not as nil).
+	To delete a property, use RemoveProperty.
+*/
+
 func (n *Node) GetBools(prop string) []bool {
-	if n.props[prop] == nil {
-		return nil
+	switch v := n.props[prop].(type) {
+	case []bool:
+		return v
+	case []interface{}:
+		// property files without types
+		res := make([]bool, len(v))
+		for i, x := range v {
+			res[i], _ = x.(bool)
+		}
+		return res
 	}
-	return n.props[prop].([]bool)
+	return nil
+}
+
+func (n *Node) GetInt(prop string) int64 {
+	switch v := n.props[prop].(type) {
+	case int64:
+		return v
+	case float64:
+		// property files without types
+		return int64(v)
+	}
+	return n.props[prop].(int64)
 }
-*/
-func (n *Node) GetInt(prop string) int64 { return n.props[prop].(int64) }
 
-/*
 func (n *Node) GetInts(prop string) []int64 {
-	if n.props[prop] == nil {
-		return nil
+	switch v := n.props[prop].(type) {
+	case []int64:
+		return v
+	case []interface{}:
+		// property files without types
+		res := make([]int64, len(v))
+		for i, x := range v {
+			f, _ := x.(float64)
+			res[i] = int64(f)
+		}
+		return res
+	}
+	return nil
+}
+
+func (n *Node) GetFloat(prop string) float64 {
+	switch v := n.props[prop].(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
 	}
-	return n.props[prop].([]int64)
+	return n.props[prop].(float64)
 }
-*/
-func (n *Node) GetFloat(prop string) float64 { return n.props[prop].(float64) }
 
-/*
 func (n *Node) GetFloats(prop string) []float64 {
-	if n.props[prop] == nil {
-		return nil
+	switch v := n.props[prop].(type) {
+	case []float64:
+		return v
+	case []interface{}:
+		// property files without types
+		res := make([]float64, len(v))
+		for i, x := range v {
+			res[i], _ = x.(float64)
+		}
+		return res
 	}
-	return n.props[prop].([]float64)
+	return nil
 }
-*/
+
 func (n *Node) GetString(prop string) string {
 	if n.props[prop] == nil {
 		return ""
@@ -179,51 +258,60 @@ func (n *Node) GetString(prop string) string {
 	return n.props[prop].(string)
 }
 
-/*
 func (n *Node) GetStrings(prop string) []string {
-	if n.props[prop] == nil {
-		return nil
+	switch v := n.props[prop].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		// property files without types
+		res := make([]string, len(v))
+		for i, x := range v {
+			res[i], _ = x.(string)
+		}
+		return res
 	}
-	return n.props[prop].([]string)
+	return nil
 }
-*/
+
+// GetTime returns nil, if the property is not set
 func (n *Node) GetTime(prop string) *time.Time {
 	t, has := n.props[prop]
 	if !has {
-		// fmt.Println("has no", prop, n.props)
-		return nil
-	}
-
-	if t == nil {
 		return nil
 	}
 
-	tt := t.(string)
-
-	ttt, err := time.Parse(time.RFC3339, tt)
-	if err != nil {
-		return nil
-	}
-
-	return &ttt
-	/*
-		if !ok {
-			fmt.Println("can't parse", tt)
+	switch tt := t.(type) {
+	case time.Time:
+		return &tt
+	case *time.Time:
+		return tt
+	case string:
+		// property files without types have times saved as strings
+		ttt, err := time.Parse(time.RFC3339, tt)
+		if err != nil {
 			return nil
 		}
-		return tt
-	*/
-	// return n.props[prop].(time.Time)
+		return &ttt
+	default:
+		return nil
+	}
 }
 
-/*
 func (n *Node) GetTimes(prop string) []time.Time {
-	if n.props[prop] == nil {
-		return nil
+	switch v := n.props[prop].(type) {
+	case []time.Time:
+		return v
+	case []interface{}:
+		// property files without types
+		res := make([]time.Time, len(v))
+		for i, x := range v {
+			str, _ := x.(string)
+			res[i], _ = time.Parse(time.RFC3339, str)
+		}
+		return res
 	}
-	return n.props[prop].([]time.Time)
+	return nil
 }
-*/
 
 // SetBlob stores a binary large object
 func (o *Node) SetBlob(prop string, rc io.Reader) {
@@ -242,36 +330,39 @@ func (o *Node) SetBool(prop string, val bool) {
 	o.props[prop] = val
 }
 
-/*
 func (o *Node) SetBools(prop string, vals ...bool) {
+	if vals == nil {
+		vals = []bool{}
+	}
 	o.dirty[prop] = true
 	o.props[prop] = vals
 }
-*/
 
 func (o *Node) SetInt(prop string, val int64) {
 	o.dirty[prop] = true
 	o.props[prop] = val
 }
 
-/*
 func (o *Node) SetInts(prop string, vals ...int64) {
+	if vals == nil {
+		vals = []int64{}
+	}
 	o.dirty[prop] = true
 	o.props[prop] = vals
 }
-*/
 
 func (o *Node) SetFloat(prop string, val float64) {
 	o.dirty[prop] = true
 	o.props[prop] = val
 }
 
-/*
 func (o *Node) SetFloats(prop string, vals ...float64) {
+	if vals == nil {
+		vals = []float64{}
+	}
 	o.dirty[prop] = true
 	o.props[prop] = vals
 }
-*/
 
 // SetString sets a string that has the max length of 255 bytes.
 // a larger string returns an error
@@ -284,35 +375,41 @@ func (o *Node) SetString(prop string, val string) error {
 	return nil
 }
 
-/*
 // SetStrings sets strings that have the max length of 255 bytes.
 // larger strings return an error
 func (o *Node) SetStrings(prop string, vals ...string) error {
-
 	for _, s := range vals {
 		if len(s) > 255 {
 			return fmt.Errorf("string %#v is too large for SetString value, use SetText", s)
 		}
-
 	}
 
+	if vals == nil {
+		vals = []string{}
+	}
 	o.dirty[prop] = true
 	o.props[prop] = vals
 	return nil
 }
-*/
 
 func (o *Node) SetTime(prop string, val *time.Time) {
 	o.dirty[prop] = true
 	o.props[prop] = val
 }
 
-/*
 func (o *Node) SetTimes(prop string, vals ...time.Time) {
+	if vals == nil {
+		vals = []time.Time{}
+	}
 	o.dirty[prop] = true
 	o.props[prop] = vals
 }
-*/
+
+// RemoveProperty removes the property from the node. The property is deleted in the store on Save
+func (o *Node) RemoveProperty(prop string) {
+	o.dirty[prop] = true
+	o.props[prop] = nil
+}
 
 func (n *Node) SaveTexts() (err error) {
 	saveTexts := map[string]string{}
@@ -337,7 +434,6 @@ func (n *Node) SaveTexts() (err error) {
 	return nil
 }
 
-/*
 func (n *Node) SaveBlobs() (err error) {
 	saveBlobs := map[string]io.Reader{}
 
@@ -360,11 +456,17 @@ func (n *Node) SaveBlobs() (err error) {
 
 	return nil
 }
-*/
 
+// Save saves the changed properties, texts and blobs of the node.
+// If the node has a schema, it is validated before and not saved, if it is invalid.
 func (n *Node) Save() (err error) {
-	//saveProps, saveTexts, saveBlobs := map[string]interface{}{}, map[string]string{}, map[string]io.Reader{}
-	saveProps, saveTexts := map[string]interface{}{}, map[string]string{}
+	if len(n.dirty) > 0 {
+		if err = n.Validate(); err != nil {
+			return err
+		}
+	}
+
+	saveProps, saveTexts, saveBlobs := map[string]interface{}{}, map[string]string{}, map[string]io.Reader{}
 	var doSaveProps, doSaveTexts bool
 	for key, isDirty := range n.dirty {
 		if isDirty {
@@ -382,13 +484,11 @@ func (n *Node) Save() (err error) {
 				continue
 			}
 
-			/*
-				blob, isBlob := n.blobs[key]
-				if isBlob {
-					saveBlobs[key] = blob
-					continue
-				}
-			*/
+			blob, isBlob := n.blobs[key]
+			if isBlob {
+				saveBlobs[key] = blob
+				continue
+			}
 		}
 	}
 
@@ -399,6 +499,7 @@ func (n *Node) Save() (err error) {
 		if err != nil {
 			return err
 		}
+		n.isNew = false
 	}
 
 	if doSaveTexts {
@@ -408,96 +509,174 @@ func (n *Node) Save() (err error) {
 		}
 	}
 
-	/*
-		if len(saveBlobs) > 0 {
-			err = n.Transaction.SaveNodeBlobs(n.Id, saveBlobs)
-			if err != nil {
-				return err
-			}
+	if len(saveBlobs) > 0 {
+		err = n.Transaction.SaveNodeBlobs(n.Id, saveBlobs)
+		if err != nil {
+			return err
 		}
-	*/
+	}
+
 	n.dirty = map[string]bool{}
 	return nil
 }
 
+// Remove removes the node from its store (see Transaction.RemoveNode). The edges of the node stay inside the
+// incoming edges of their targets in other shards, use Resolver.RemoveNode to remove them there too
 func (n *Node) Remove() (err error) {
 	return n.Transaction.RemoveNode(n.Id)
 }
 
 // NewEdge creates a new Edge to the target edge, by the way creating a property node based on the given
-// properties. The property node is part of the same shard as Node
+// properties. The property node is part of the same shard as Node.
+// An existing default edge of the category to the target is replaced and its property node removed.
 func (n *Node) NewEdge(category string, to *Node, props map[string]interface{}) error {
-	if len(props) == 0 {
-		edge := NewEdge(category, n, to, nil)
-		return edge.Save()
+	return n.NewWeightedEdge(category, to, 0, props)
+}
+
+// NewWeightedEdge is like NewEdge, but sets the weight of the edge. The weight is saved
+// inside the edges file, so no property node is needed for it
+func (n *Node) NewWeightedEdge(category string, to *Node, weight float64, props map[string]interface{}) error {
+	old, err := n.getEdge(category, to, "")
+	if err != nil {
+		return err
 	}
-	propNode := NewNode(n.Transaction, "")
-	propNode.props = props
 
-	for k, _ := range props {
-		propNode.dirty[k] = true
+	if old != nil && old.Properties != nil {
+		if err := old.Properties.Remove(); err != nil {
+			return err
+		}
 	}
 
-	if err := propNode.Save(); err != nil {
-		return err
+	_, err = n.newEdge(category, "", to, weight, props)
+	return err
+}
+
+// AddEdge adds a new edge of the category to the target, even if there are already edges of the category
+// between both nodes. The new edge gets its own id and property node, see NewEdge.
+func (n *Node) AddEdge(category string, to *Node, props map[string]interface{}) (*Edge, error) {
+	return n.AddWeightedEdge(category, to, 0, props)
+}
+
+// AddWeightedEdge is like AddEdge, but sets the weight of the edge
+func (n *Node) AddWeightedEdge(category string, to *Node, weight float64, props map[string]interface{}) (*Edge, error) {
+	return n.newEdge(category, uuid.NewV4().String(), to, weight, props)
+}
+
+func (n *Node) newEdge(category, id string, to *Node, weight float64, props map[string]interface{}) (*Edge, error) {
+	edge := NewEdge(category, n, to, nil)
+	edge.ID = id
+	edge.Weight = weight
+
+	if len(props) > 0 {
+		propNode := NewNode(n.Transaction, "")
+		propNode.props = props
+
+		for k, _ := range props {
+			propNode.dirty[k] = true
+		}
+
+		if err := propNode.Save(); err != nil {
+			return nil, err
+		}
+		edge.Properties = propNode
 	}
 
-	edge := NewEdge(category, n, to, propNode)
-	return edge.Save()
+	if err := edge.Save(); err != nil {
+		return nil, err
+	}
+	return edge, nil
 }
 
-// RemoveEdge removes the edge of the given category, removing the property node of the edge
+// RemoveEdge removes all edges of the given category to the target node, removing the property nodes of the edges
 func (n *Node) RemoveEdge(category string, to *Node) error {
-	edges, err := n.Transaction.GetEdges(category, n.Id)
+	edges, err := n.GetEdgesTo(category, to)
 	if err != nil {
 		return err
 	}
-	if len(edges) == 0 {
-		return nil
-	}
 
-	propID, has := edges[to.Transaction.Shard()+"-"+to.Id]
-
-	if !has {
-		return nil
+	for _, e := range edges {
+		if err := e.Delete(); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	propNode := NewNode(n.Transaction, propID)
-	if err := propNode.Remove(); err != nil {
+// RemoveEdgeByID removes the edge of the given category to the target node that has the given id,
+// removing the property node of the edge
+func (n *Node) RemoveEdgeByID(category string, to *Node, id string) error {
+	e, err := n.getEdge(category, to, id)
+	if err != nil || e == nil {
 		return err
 	}
-	delete(edges, to.Transaction.Shard()+"-"+to.Id)
-	if len(edges) == 0 {
-		return n.Transaction.RemoveEdges(category, n.Id)
-	}
-	return n.Transaction.SaveEdges(category, n.Id, edges)
+	return e.Delete()
 }
 
-// GetEdge returns nil, if the edge could not be found, does not load the properties of the property edge
-func (n *Node) GetEdge(category string, to *Node) (*Edge, error) {
+// getEdge returns the edge with the given id or nil, if it does not exist
+func (n *Node) getEdge(category string, to *Node, id string) (*Edge, error) {
 	edges, err := n.Transaction.GetEdges(category, n.Id)
 	if err != nil {
 		return nil, err
 	}
-	if len(edges) == 0 {
-		return nil, nil
-	}
 
-	propID, has := edges[to.Transaction.Shard()+"-"+to.Id]
+	val, has := edges[EdgeKey(to.Transaction.Shard()+"-"+to.Id, id)]
 
 	if !has {
 		return nil, nil
 	}
 
-	if propID == "" {
-		return NewEdge(category, n, to, nil), nil
+	return newEdgeFromValue(category, id, n, to, val)
+}
+
+// GetEdge returns nil, if the edge could not be found, does not load the properties of the property edge.
+// If there are parallel edges to the target, the default edge is returned, or if there is none,
+// the edge with the smallest id
+func (n *Node) GetEdge(category string, to *Node) (*Edge, error) {
+	edges, err := n.GetEdgesTo(category, to)
+	if err != nil || len(edges) == 0 {
+		return nil, err
+	}
+	return edges[0], nil
+}
+
+// GetEdgesTo returns all edges of the given category to the target node, sorted by their ids.
+// The default edge (without id) comes first.
+func (n *Node) GetEdgesTo(category string, to *Node) ([]*Edge, error) {
+	edges, err := n.Transaction.GetEdges(category, n.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*Edge{}
+
+	for key, val := range edges {
+		shard, toID, id, err := SplitEdgeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if shard != to.Transaction.Shard() || toID != to.Id {
+			continue
+		}
+		e, err := newEdgeFromValue(category, id, n, to, val)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
 	}
 
-	return NewEdge(category, n, to, NewNode(n.Transaction, propID)), nil
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+// EdgeCategories returns the sorted categories in which the node has outgoing edges
+func (n *Node) EdgeCategories() ([]string, error) {
+	return n.Transaction.GetEdgeCategories(n.Id)
 }
 
-// GetEdges returns all edges for the given category. it does however not load the properties neither
-// of the property node nor of the target node
+// GetEdges returns all edges for the given category in their order (see ordered.go). it does however not load
+// the properties neither of the property node nor of the target node
 // the given target store determines from which store the edges are given
 func (n *Node) GetEdges(target Transaction, category string) ([]*Edge, error) {
 	edges, err := n.Transaction.GetEdges(category, n.Id)
@@ -510,19 +689,53 @@ func (n *Node) GetEdges(target Transaction, category string) ([]*Edge, error) {
 
 	res := []*Edge{}
 
-	for to, propID := range edges {
-		shard, toID, err := SplitID(to)
+	for to, val := range edges {
+		shard, toID, id, err := SplitEdgeKey(to)
 		if err != nil {
 			return nil, err
 		}
 		if shard == target.Shard() {
-
-			if propID == "" {
-				res = append(res, NewEdge(category, n, NewNode(target, toID), nil))
-			} else {
-				res = append(res, NewEdge(category, n, NewNode(target, toID), NewNode(n.Transaction, propID)))
+			e, err := newEdgeFromValue(category, id, n, NewNode(target, toID), val)
+			if err != nil {
+				return nil, err
 			}
+			res = append(res, e)
+		}
+	}
+
+	sortEdges(res)
+	return res, nil
+}
+
+// GetIncomingEdges returns all edges of the given category that point to the node from nodes of the
+// given source store. it does however not load the properties neither of the property node nor of the source node.
+// the edges have no position, since positions are only defined among the edges of a source node
+func (n *Node) GetIncomingEdges(source Transaction, category string) ([]*Edge, error) {
+	incoming, err := n.Transaction.GetIncomingEdges(category, n.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(incoming) == 0 {
+		return nil, nil
+	}
+
+	res := []*Edge{}
+
+	for from, val := range incoming {
+		shard, fromID, id, err := SplitEdgeKey(from)
+		if err != nil {
+			return nil, err
+		}
+		if shard != source.Shard() {
+			continue
+		}
+
+		e, err := newEdgeFromValue(category, id, NewNode(source, fromID), n, val)
+		if err != nil {
+			return nil, err
 		}
+		e.Position = 0
+		res = append(res, e)
 	}
 
 	return res, nil
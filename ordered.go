@@ -0,0 +1,162 @@
+package zoom
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	ordered edges
+
+	The edges of a category may be ordered by their position (see Edge.Position). The position is part of the
+	edge value inside the edges file, so changing the order only rewrites the edges file of the source node, but
+	neither the property nodes nor the incoming edges.
+
+	Edges are returned by Node.GetEdges in their order: the ordered edges first, followed by the edges without
+	position sorted by their keys. Whenever the order is changed, all edges of the category get a position.
+*/
+
+// edgeEntry is an edge as saved inside the edges file
+type edgeEntry struct {
+	key      string
+	propID   string
+	weight   float64
+	position int
+}
+
+func lessPosition(posA int, keyA string, posB int, keyB string) bool {
+	switch {
+	case posA == posB:
+		return keyA < keyB
+	case posA == 0:
+		return false
+	case posB == 0:
+		return true
+	default:
+		return posA < posB
+	}
+}
+
+// sortEdges sorts the edges by their position, see above
+func sortEdges(edges []*Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		return lessPosition(edges[i].Position, edges[i].key(), edges[j].Position, edges[j].key())
+	})
+}
+
+// edgeOrder returns the edges of the category in their order
+func (n *Node) edgeOrder(category string) ([]edgeEntry, error) {
+	edges, err := n.Transaction.GetEdges(category, n.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]edgeEntry, 0, len(edges))
+	for key, val := range edges {
+		propID, weight, position, err := ParseEdgeValue(val)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, edgeEntry{key: key, propID: propID, weight: weight, position: position})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return lessPosition(entries[i].position, entries[i].key, entries[j].position, entries[j].key)
+	})
+	return entries, nil
+}
+
+// saveEdgeOrder saves the edges with positions in the given order
+func (n *Node) saveEdgeOrder(category string, entries []edgeEntry) error {
+	edges := make(map[string]string, len(entries))
+	for i, e := range entries {
+		edges[e.key] = FormatEdgeValue(e.propID, e.weight, i+1)
+	}
+	return n.Transaction.SaveEdges(category, n.Id, edges)
+}
+
+func indexOfEntry(entries []edgeEntry, key string) int {
+	for i, e := range entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveEntry moves the entry at position from to the index. If index is negative or
+// too large, the entry is moved to the end.
+func moveEntry(entries []edgeEntry, from, index int) []edgeEntry {
+	e := entries[from]
+	entries = append(entries[:from], entries[from+1:]...)
+	if index < 0 || index > len(entries) {
+		index = len(entries)
+	}
+	entries = append(entries, edgeEntry{})
+	copy(entries[index+1:], entries[index:])
+	entries[index] = e
+	return entries
+}
+
+// AddEdgeAt adds a new edge (see AddEdge) at the given 0-based index of the order of the edges of the category.
+// If index is negative or larger than the number of edges, the edge is appended.
+func (n *Node) AddEdgeAt(category string, to *Node, index int, props map[string]interface{}) (*Edge, error) {
+	e, err := n.AddEdge(category, to, props)
+	if err != nil {
+		return nil, err
+	}
+	return e, e.Move(index)
+}
+
+// Move moves the edge to the given 0-based index of the order of the edges of its category.
+// If index is negative or larger than the index of the last edge, the edge is moved to the end.
+func (e *Edge) Move(index int) error {
+	entries, err := e.From.edgeOrder(e.Category)
+	if err != nil {
+		return err
+	}
+
+	from := indexOfEntry(entries, e.key())
+	if from == -1 {
+		return fmt.Errorf("edge %s from %s to %s does not exist", e.Category, e.From.Id, e.To.Id)
+	}
+
+	entries = moveEntry(entries, from, index)
+	if err := e.From.saveEdgeOrder(e.Category, entries); err != nil {
+		return err
+	}
+	e.Position = indexOfEntry(entries, e.key()) + 1
+	return nil
+}
+
+// ReorderEdges orders the edges of the category, so that the given edges come first in the given order,
+// followed by the other edges of the category in their previous order
+func (n *Node) ReorderEdges(category string, order []*Edge) error {
+	entries, err := n.edgeOrder(category)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range order {
+		if e.Category != category || e.From.Transaction.Shard() != n.Transaction.Shard() || e.From.Id != n.Id {
+			return fmt.Errorf("edge %s from %s to %s is no %s edge of %s", e.Category, e.From.Id, e.To.Id, category, n.Id)
+		}
+		from := indexOfEntry(entries, e.key())
+		if from == -1 {
+			return fmt.Errorf("edge %s from %s to %s does not exist", e.Category, e.From.Id, e.To.Id)
+		}
+		if from < i {
+			return fmt.Errorf("edge %s from %s to %s is given twice", e.Category, e.From.Id, e.To.Id)
+		}
+		entries = moveEntry(entries, from, i)
+	}
+
+	if err := n.saveEdgeOrder(category, entries); err != nil {
+		return err
+	}
+
+	for i, e := range order {
+		e.Position = i + 1
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package zoom_test
+
+import (
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func edgeTargets(t *testing.T, n *zoom.Node, st zoom.Transaction, category string) []string {
+	edges, err := n.GetEdges(st, category)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, e := range edges {
+		res = append(res, e.To.Id)
+	}
+	return res
+}
+
+func sameOrder(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrderedEdges(t *testing.T) {
+	store := memstore.New("shard1")
+
+	playlist := zoom.NewNode(store, "")
+	a := zoom.NewNode(store, "")
+	b := zoom.NewNode(store, "")
+	c := zoom.NewNode(store, "")
+
+	ea, err := playlist.AddEdgeAt("song", a, -1, map[string]interface{}{"Note": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eb, err := playlist.AddEdgeAt("song", b, -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := playlist.AddEdgeAt("song", c, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same song twice
+	if _, err := playlist.AddEdgeAt("song", a, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := edgeTargets(t, playlist, store, "song"), []string{c.Id, a.Id, a.Id, b.Id}; !sameOrder(got, expected) {
+		t.Fatalf("after AddEdgeAt got %v, expected %v", got, expected)
+	}
+
+	if err := eb.Move(0); err != nil {
+		t.Fatal(err)
+	}
+	if eb.Position != 1 {
+		t.Errorf("position after Move(0) is %d, expected 1", eb.Position)
+	}
+
+	if got, expected := edgeTargets(t, playlist, store, "song"), []string{b.Id, c.Id, a.Id, a.Id}; !sameOrder(got, expected) {
+		t.Fatalf("after Move got %v, expected %v", got, expected)
+	}
+
+	if err := playlist.ReorderEdges("song", []*zoom.Edge{ea}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := edgeTargets(t, playlist, store, "song"), []string{a.Id, b.Id, c.Id, a.Id}; !sameOrder(got, expected) {
+		t.Fatalf("after ReorderEdges got %v, expected %v", got, expected)
+	}
+
+	// the property node is kept
+	edges, _ := playlist.GetEdges(store, "song")
+	if edges[0].ID != ea.ID || edges[0].Properties == nil || edges[0].Properties.Id != ea.Properties.Id {
+		t.Fatalf("reordered edge lost its property node")
+	}
+	if err := edges[0].Properties.LoadProperties([]string{"Note"}); err != nil {
+		t.Fatal(err)
+	}
+	if edges[0].Properties.GetString("Note") != "a" {
+		t.Errorf("Note is %#v, expected \"a\"", edges[0].Properties.GetString("Note"))
+	}
+
+	// saving an edge keeps its position
+	ea.Weight = 3
+	ea.Position = 0
+	if err := ea.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if ea.Position != 1 {
+		t.Errorf("position after Save is %d, expected 1", ea.Position)
+	}
+
+	if in, _ := a.GetIncomingEdges(store, "song"); len(in) != 2 {
+		t.Errorf("got %d incoming edges, expected 2", len(in))
+	}
+
+	if err := playlist.ReorderEdges("song", []*zoom.Edge{ea, ea}); err == nil {
+		t.Errorf("ReorderEdges with the same edge twice should return an error")
+	}
+
+	if err := eb.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eb.Move(0); err == nil {
+		t.Errorf("Move of a removed edge should return an error")
+	}
+}
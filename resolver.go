@@ -0,0 +1,159 @@
+package zoom
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UnknownShardError is returned by a Resolver, if a node belongs to a shard it has no transaction for
+type UnknownShardError struct {
+	Shard string
+}
+
+func (u *UnknownShardError) Error() string {
+	return fmt.Sprintf("no transaction for shard %#v", u.Shard)
+}
+
+// Resolver binds the nodes that are referenced by edges to the transactions of their shards,
+// so that edges between shards can be followed
+type Resolver struct {
+	shards map[string]Transaction
+}
+
+// NewResolver returns a resolver for the shards of the given transactions
+func NewResolver(trs ...Transaction) *Resolver {
+	r := &Resolver{shards: map[string]Transaction{}}
+	for _, tr := range trs {
+		r.shards[tr.Shard()] = tr
+	}
+	return r
+}
+
+// Transaction returns the transaction for the given shard
+func (r *Resolver) Transaction(shard string) (Transaction, error) {
+	tr, has := r.shards[shard]
+	if !has {
+		return nil, &UnknownShardError{shard}
+	}
+	return tr, nil
+}
+
+// Node returns the node for the given id of the form "shard-uuid", bound to the transaction of its shard
+func (r *Resolver) Node(id string) (*Node, error) {
+	shard, uuid, err := SplitID(id)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := r.Transaction(shard)
+	if err != nil {
+		return nil, err
+	}
+	return NewNode(tr, uuid), nil
+}
+
+// Edges returns all edges of the given category from the node, with the target nodes bound to the
+// transactions of their shards. The edges are returned in their order (see ordered.go).
+// If a target belongs to a shard without transaction, an *UnknownShardError is returned.
+func (r *Resolver) Edges(n *Node, category string) ([]*Edge, error) {
+	edges, err := n.Transaction.GetEdges(category, n.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0, len(edges))
+	for to := range edges {
+		targets = append(targets, to)
+	}
+	sort.Strings(targets)
+
+	res := []*Edge{}
+	for _, to := range targets {
+		shard, uuid, id, err := SplitEdgeKey(to)
+		if err != nil {
+			return nil, err
+		}
+		tr, err := r.Transaction(shard)
+		if err != nil {
+			return nil, err
+		}
+		e, err := newEdgeFromValue(category, id, n, NewNode(tr, uuid), edges[to])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	sortEdges(res)
+	return res, nil
+}
+
+// IncomingEdges returns all edges of the given category to the node, with the source nodes bound to the
+// transactions of their shards. The edges are sorted by the ids of the source nodes and the ids of the edges.
+// If a source belongs to a shard without transaction, an *UnknownShardError is returned.
+func (r *Resolver) IncomingEdges(n *Node, category string) ([]*Edge, error) {
+	incoming, err := n.Transaction.GetIncomingEdges(category, n.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := map[string]bool{}
+	for from := range incoming {
+		shard, _, _, err := SplitEdgeKey(from)
+		if err != nil {
+			return nil, err
+		}
+		shards[shard] = true
+	}
+
+	res := []*Edge{}
+	for shard := range shards {
+		source, err := r.Transaction(shard)
+		if err != nil {
+			return nil, err
+		}
+		edges, err := n.GetIncomingEdges(source, category)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, edges...)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		fi, fj := res[i].From.Shard()+"-"+res[i].From.Id, res[j].From.Shard()+"-"+res[j].From.Id
+		if fi != fj {
+			return fi < fj
+		}
+		// parallel edges from the same source
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+// RemoveNode removes the node like Node.Remove and also removes its edges from the incoming edges of
+// the targets in other shards, which the store of the node can't reach.
+// If a target belongs to a shard without transaction, an *UnknownShardError is returned and nothing is removed.
+func (r *Resolver) RemoveNode(n *Node) error {
+	categories, err := n.Transaction.GetEdgeCategories(n.Id)
+	if err != nil {
+		return err
+	}
+
+	var foreign []*Edge
+	for _, category := range categories {
+		edges, err := r.Edges(n, category)
+		if err != nil {
+			return err
+		}
+		for _, e := range edges {
+			if e.To.Shard() != n.Shard() {
+				foreign = append(foreign, e)
+			}
+		}
+	}
+
+	for _, e := range foreign {
+		if err := e.removeIncoming(); err != nil {
+			return err
+		}
+	}
+	return n.Remove()
+}
@@ -0,0 +1,188 @@
+package zoom_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func TestResolver(t *testing.T) {
+	persons := memstore.New("persons")
+	groups := memstore.New("groups")
+	cities := memstore.New("cities")
+
+	donald := zoom.NewNode(persons, "")
+	daisy := zoom.NewNode(persons, "")
+	ducks := zoom.NewNode(groups, "")
+	duckburg := zoom.NewNode(cities, "")
+
+	for _, n := range []*zoom.Node{donald, daisy, ducks, duckburg} {
+		n.SetString("Name", "x")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := donald.NewEdge("knows", daisy, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := donald.NewEdge("knows", ducks, map[string]interface{}{"Since": "1934"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := donald.NewWeightedEdge("knows", duckburg, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := zoom.NewResolver(persons, groups, cities)
+
+	edges, err := r.Edges(donald, "knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edges) != 3 {
+		t.Fatalf("got %d edges, expected 3", len(edges))
+	}
+
+	expected := map[string]zoom.Transaction{
+		daisy.ID():    persons,
+		ducks.ID():    groups,
+		duckburg.ID(): cities,
+	}
+
+	for _, e := range edges {
+		tr, has := expected[e.To.ID()]
+		if !has {
+			t.Errorf("unexpected target %s", e.To.ID())
+			continue
+		}
+		if e.To.Transaction != tr {
+			t.Errorf("target %s is bound to shard %s, expected %s", e.To.ID(), e.To.Shard(), tr.Shard())
+		}
+		if e.To.ID() == ducks.ID() && (e.Properties == nil || e.Properties.Transaction != persons) {
+			t.Errorf("property node of edge to ducks should be bound to the shard of donald")
+		}
+		if e.To.ID() == duckburg.ID() && e.Weight != 2 {
+			t.Errorf("weight of edge to duckburg = %v, expected 2", e.Weight)
+		}
+	}
+
+	if err := daisy.NewEdge("knows", ducks, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	incoming, err := r.IncomingEdges(ducks, "knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := sourceIDs(incoming); len(ids) != 2 || !ids[donald.ID()] || !ids[daisy.ID()] {
+		t.Errorf("incoming edges of ducks from %v, expected donald and daisy", ids)
+	}
+
+	_, err = zoom.NewResolver(persons).Edges(donald, "knows")
+	if _, ok := err.(*zoom.UnknownShardError); !ok {
+		t.Errorf("expected *zoom.UnknownShardError, got %T %v", err, err)
+	}
+}
+
+func TestResolverParallelIncomingEdges(t *testing.T) {
+	persons := memstore.New("persons")
+	groups := memstore.New("groups")
+
+	donald := zoom.NewNode(persons, "")
+	ducks := zoom.NewNode(groups, "")
+
+	var ids []string
+	for i := 0; i < 20; i++ {
+		e, err := donald.AddEdge("visited", ducks, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	sort.Strings(ids)
+
+	incoming, err := zoom.NewResolver(persons, groups).IncomingEdges(ducks, "visited")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range incoming {
+		got = append(got, e.ID)
+	}
+
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("incoming edges in order %v, expected %v", got, ids)
+	}
+}
+
+func TestResolverRemoveNode(t *testing.T) {
+	persons := memstore.New("persons")
+	groups := memstore.New("groups")
+
+	donald := zoom.NewNode(persons, "")
+	daisy := zoom.NewNode(persons, "")
+	ducks := zoom.NewNode(groups, "")
+
+	for _, n := range []*zoom.Node{donald, daisy, ducks} {
+		n.SetString("Name", "x")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, to := range []*zoom.Node{daisy, ducks} {
+		if err := donald.NewEdge("knows", to, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the store of the node only reaches the targets inside its shard
+	if err := donald.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if in, err := persons.GetIncomingEdges("knows", daisy.Id); err != nil || len(in) != 0 {
+		t.Errorf("incoming edges of daisy after Node.Remove = %v, %v, expected none", in, err)
+	}
+
+	if in, err := groups.GetIncomingEdges("knows", ducks.Id); err != nil || len(in) != 1 {
+		t.Errorf("incoming edges of ducks after Node.Remove = %v, %v, expected the stale edge", in, err)
+	}
+
+	if err := groups.RemoveIncomingEdges("knows", ducks.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	donald = zoom.NewNode(persons, "")
+	donald.SetString("Name", "x")
+	if err := donald.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, to := range []*zoom.Node{daisy, ducks} {
+		if err := donald.NewEdge("knows", to, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := zoom.NewResolver(persons).RemoveNode(donald)
+	if _, ok := err.(*zoom.UnknownShardError); !ok {
+		t.Errorf("expected *zoom.UnknownShardError, got %T %v", err, err)
+	}
+
+	if err := zoom.NewResolver(persons, groups).RemoveNode(donald); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []*zoom.Node{daisy, ducks} {
+		if in, err := n.Transaction.GetIncomingEdges("knows", n.Id); err != nil || len(in) != 0 {
+			t.Errorf("incoming edges of %s after Resolver.RemoveNode = %v, %v, expected none", n.ID(), in, err)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package zoom
+
+import "errors"
+
+// StopScan may be returned by the function given to Transaction.ScanNodes to end the scan without error
+var StopScan = errors.New("stop scan")
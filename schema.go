@@ -1,6 +1,36 @@
 package zoom
 
+import (
+	"fmt"
+	"sync"
+)
+
 /*
+	schemas
+
+	A schema is a node with the properties "name" and "schema-rules". The rules are names of
+	validation functions that have been registered via RegisterSchemaRule.
+	A node belongs to a schema, if it has an edge of the category SchemaCategory to the schema node
+	(see Node.SetSchema). Node.Save validates the node against its schema and refuses to save it,
+	if one of the rules fails.
+
+	Rules are called with the node that is about to be saved. Properties and texts that are
+	neither loaded nor set are not part of the node, so rules that need the stored values
+	should use RequireProperties or load them via node.Transaction.GetNodeProperties.
+*/
+
+// SchemaCategory is the edge category that links a node to its schema
+const SchemaCategory = "schema"
+
+// schemaState is the knowledge of a Node about its schema
+type schemaState int
+
+const (
+	schemaUnknown schemaState = iota // not looked up yet
+	schemaNone
+	schemaSet
+)
+
 type SchemaRules []string
 
 func (s *SchemaRules) Get(data map[string]interface{}) bool {
@@ -8,18 +38,94 @@ func (s *SchemaRules) Get(data map[string]interface{}) bool {
 	if !has {
 		return false
 	}
-	nstr, ok := nn.([]string)
-	if !ok {
+	switch nstr := nn.(type) {
+	case []string:
+		*s = nstr
+	case []interface{}:
+		// property files without types
+		rules := make([]string, len(nstr))
+		for i, r := range nstr {
+			str, ok := r.(string)
+			if !ok {
+				return false
+			}
+			rules[i] = str
+		}
+		*s = rules
+	default:
 		return false
 	}
-	*s = nstr
 	return true
 }
 
-var schemaRules = map[string]func(o *Node) error{}
+var (
+	schemaRulesMx sync.RWMutex
+	schemaRules   = map[string]func(o *Node) error{}
+)
 
 func RegisterSchemaRule(name string, fn func(o *Node) error) {
+	schemaRulesMx.Lock()
 	schemaRules[name] = fn
+	schemaRulesMx.Unlock()
+}
+
+func getSchemaRule(name string) (fn func(o *Node) error, has bool) {
+	schemaRulesMx.RLock()
+	fn, has = schemaRules[name]
+	schemaRulesMx.RUnlock()
+	return
+}
+
+// RequireProperties returns a rule that fails, if one of the given properties is neither set
+// on the node nor saved in the store
+func RequireProperties(props ...string) func(o *Node) error {
+	return func(o *Node) error {
+		var missing []string
+		for _, p := range props {
+			v, has := o.props[p]
+			if !has {
+				missing = append(missing, p)
+				continue
+			}
+			// the property is about to be removed
+			if v == nil {
+				return fmt.Errorf("property %s is missing", p)
+			}
+		}
+
+		if len(missing) == 0 {
+			return nil
+		}
+
+		// the properties of a new node have not been saved yet
+		stored := map[string]interface{}{}
+		if !o.isNew {
+			var err error
+			stored, err = o.Transaction.GetNodeProperties(o.Id, missing)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, p := range missing {
+			if stored[p] == nil {
+				return fmt.Errorf("property %s is missing", p)
+			}
+		}
+		return nil
+	}
+}
+
+// ValidationError is returned, if a node does not fulfill a rule of its schema
+type ValidationError struct {
+	NodeID string
+	Schema string
+	Rule   string
+	Err    error
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("node %s is invalid for schema %#v (rule %#v): %s", v.NodeID, v.Schema, v.Rule, v.Err)
 }
 
 type Schema struct {
@@ -36,15 +142,58 @@ func (sc *Schema) Name() string {
 	return sc.name
 }
 
+func (sc *Schema) Rules() []string {
+	return sc.rules
+}
+
 func (sc *Schema) Validate(o *Node) error {
 	for _, rl := range sc.rules {
-		if err := schemaRules[rl](o); err != nil {
-			return err
+		fn, has := getSchemaRule(rl)
+		if !has {
+			return fmt.Errorf("schema %#v: rule %#v is not registered", sc.name, rl)
+		}
+		if err := fn(o); err != nil {
+			return &ValidationError{NodeID: o.Id, Schema: sc.name, Rule: rl, Err: err}
 		}
 	}
 	return nil
 }
 
+// NewSchema creates and saves a schema node with the given name and rules.
+// All rules must have been registered.
+func NewSchema(tr Transaction, name string, rules ...string) (*Node, error) {
+	for _, rl := range rules {
+		if _, has := getSchemaRule(rl); !has {
+			return nil, fmt.Errorf("rule %#v is not registered", rl)
+		}
+	}
+
+	n := NewNode(tr, "")
+	if err := n.SetString("name", name); err != nil {
+		return nil, err
+	}
+	if err := n.SetStrings("schema-rules", rules...); err != nil {
+		return nil, err
+	}
+	if err := n.Save(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// LoadSchema loads the schema node with the given id
+func LoadSchema(tr Transaction, id string) (*Schema, error) {
+	n := NewNode(tr, id)
+	if err := n.LoadProperties([]string{"name", "schema-rules"}); err != nil {
+		return nil, err
+	}
+	stru, ok := MkSchema(n)
+	if !ok {
+		return nil, fmt.Errorf("node %s is not a schema", id)
+	}
+	return stru.(*Schema), nil
+}
+
 func MkSchema(o *Node) (stru Identifiable, ok bool) {
 	var n Name
 	if !o.Property(&n) {
@@ -61,6 +210,84 @@ func MkSchema(o *Node) (stru Identifiable, ok bool) {
 	}, true
 }
 
+// SetSchema validates the node against the given schema node and links it to the schema,
+// replacing any previous schema. The schema must be in the shard of the node.
+func (n *Node) SetSchema(schema *Node) error {
+	if schema.Shard() != n.Shard() {
+		return fmt.Errorf("schema %s of node %s must be in shard %s, not in %s", schema.Id, n.Id, n.Shard(), schema.Shard())
+	}
+
+	sc, err := LoadSchema(schema.Transaction, schema.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := sc.Validate(n); err != nil {
+		return err
+	}
+
+	old, err := n.GetEdges(schema.Transaction, SchemaCategory)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range old {
+		if err := e.Remove(); err != nil {
+			return err
+		}
+	}
+
+	// edges to schemas of other shards
+	if err := n.Transaction.RemoveEdges(SchemaCategory, n.Id); err != nil {
+		return err
+	}
+
+	if err := NewEdge(SchemaCategory, n, schema, nil).Save(); err != nil {
+		return err
+	}
+	n.schema = schemaSet
+	return nil
+}
+
+// Schema returns the schema of the node or nil, if the node has no schema
+func (n *Node) Schema() (*Schema, error) {
+	edges, err := n.Transaction.GetEdges(SchemaCategory, n.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	for to := range edges {
+		shard, id, _, err := SplitEdgeKey(to)
+		if err != nil {
+			return nil, err
+		}
+		if shard != n.Shard() {
+			return nil, fmt.Errorf("schema %s of node %s is in another shard", to, n.Id)
+		}
+		return LoadSchema(n.Transaction, id)
+	}
+	return nil, nil
+}
+
+// Validate validates the node against its schema. A node without schema is always valid.
+// Whether a node has a schema is only looked up once per Node value: new nodes and nodes that
+// had no schema are not looked up again, unless SetSchema is called on the same Node value.
+func (n *Node) Validate() error {
+	if n.schema == schemaNone {
+		return nil
+	}
+	sc, err := n.Schema()
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		n.schema = schemaNone
+		return nil
+	}
+	n.schema = schemaSet
+	return sc.Validate(n)
+}
+
 type Validateable struct {
 	id string
 	*Node
@@ -76,27 +303,17 @@ func (v *Validateable) Validate() error {
 }
 
 func MkValidateable(o *Node) (stru Identifiable, ok bool) {
-	rel := o.Relation("schema")
-	if rel == nil {
-		return nil, false
-	}
-	sc, is := MkSchema(rel)
-	if !is {
-		return nil, false
-	}
-	scc, isOk := sc.(*Schema)
-	if !isOk {
+	sc, err := o.Schema()
+	if err != nil || sc == nil {
 		return nil, false
 	}
 
 	return &Validateable{
 		id:     o.ID(),
 		Node:   o,
-		Schema: scc,
+		Schema: sc,
 	}, true
-
 }
 
 var _ StructerFunc = MkSchema
 var _ StructerFunc = MkValidateable
-*/
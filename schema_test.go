@@ -0,0 +1,216 @@
+package zoom_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func init() {
+	zoom.RegisterSchemaRule("person-required", zoom.RequireProperties("FirstName", "LastName"))
+	zoom.RegisterSchemaRule("adult", func(n *zoom.Node) error {
+		age, has := n.Properties()["Age"].(int64)
+		if has && age < 18 {
+			return fmt.Errorf("age %d is below 18", age)
+		}
+		return nil
+	})
+}
+
+func TestSchema(t *testing.T) {
+	store := memstore.New("shard1")
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "add person"}, func(tr zoom.Transaction) error {
+		schema, err := zoom.NewSchema(tr, "person", "person-required", "adult")
+		if err != nil {
+			return err
+		}
+
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		n.SetString("FirstName", "Donald")
+		n.SetString("LastName", "Duck")
+		n.SetInt("Age", 44)
+		if err := n.SetSchema(schema); err != nil {
+			return err
+		}
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// partial update that keeps the required properties stored
+	err = store.Transaction(zoom.CommitMessage{Command: "update"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.SetInt("Age", 45)
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Errorf("valid update failed: %s", err)
+	}
+
+	err = store.Transaction(zoom.CommitMessage{Command: "invalid update"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.SetInt("Age", 12)
+		return n.Save()
+	})
+
+	if _, ok := err.(*zoom.ValidationError); !ok {
+		t.Fatalf("expected *zoom.ValidationError, got %#v", err)
+	}
+
+	err = store.Transaction(zoom.CommitMessage{Command: "remove required"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.RemoveProperty("LastName")
+		return n.Save()
+	})
+
+	if _, ok := err.(*zoom.ValidationError); !ok {
+		t.Fatalf("expected *zoom.ValidationError, got %#v", err)
+	}
+
+	n := zoom.NewNode(store, id)
+	if err := n.LoadProperties([]string{"Age", "LastName"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if age := n.GetInt("Age"); age != 45 {
+		t.Errorf("Age = %d, expected 45", age)
+	}
+
+	if last := n.GetString("LastName"); last != "Duck" {
+		t.Errorf("LastName = %#v, expected %#v", last, "Duck")
+	}
+
+	sc, err := n.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sc == nil || sc.Name() != "person" {
+		t.Errorf("Schema() = %#v, expected person schema", sc)
+	}
+}
+
+func TestSchemaUnregisteredRule(t *testing.T) {
+	store := memstore.New("shard1")
+
+	err := store.Transaction(zoom.CommitMessage{Command: "add schema"}, func(tr zoom.Transaction) error {
+		_, err := zoom.NewSchema(tr, "broken", "not-registered")
+		return err
+	})
+
+	if err == nil {
+		t.Errorf("expected error for unregistered rule")
+	}
+}
+
+func TestSchemaOtherShard(t *testing.T) {
+	schemas := memstore.New("schemas")
+	persons := memstore.New("persons")
+
+	schema, err := zoom.NewSchema(schemas, "person", "person-required")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := zoom.NewNode(persons, "")
+	n.SetString("FirstName", "Donald")
+	n.SetString("LastName", "Duck")
+
+	if err := n.SetSchema(schema); err == nil {
+		t.Fatalf("SetSchema() with a schema of another shard should return an error")
+	}
+
+	if err := n.Save(); err != nil {
+		t.Errorf("Save() after rejected schema failed: %s", err)
+	}
+}
+
+// schemaStore counts the lookups of schema edges and fails to read the properties of the node failProps
+type schemaStore struct {
+	*memstore.Store
+	schemaLookups int
+	failProps     string
+}
+
+var errStorage = errors.New("storage failure")
+
+func (s *schemaStore) GetEdges(category, uuid string) (map[string]string, error) {
+	if category == zoom.SchemaCategory {
+		s.schemaLookups++
+	}
+	return s.Store.GetEdges(category, uuid)
+}
+
+func (s *schemaStore) GetNodeProperties(uuid string, requested []string) (map[string]interface{}, error) {
+	if uuid == s.failProps {
+		return nil, errStorage
+	}
+	return s.Store.GetNodeProperties(uuid, requested)
+}
+
+func TestSchemaLookups(t *testing.T) {
+	store := &schemaStore{Store: memstore.New("shard1")}
+
+	n := zoom.NewNode(store, "")
+	n.SetString("FirstName", "Donald")
+	if err := n.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.NewEdge("knows", zoom.NewNode(store, ""), map[string]interface{}{"Since": "1934"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.schemaLookups != 0 {
+		t.Errorf("saving new nodes looked up their schema %d times", store.schemaLookups)
+	}
+
+	loaded := zoom.NewNode(store, n.ID())
+	for i := 0; i < 3; i++ {
+		loaded.SetInt("Age", int64(40+i))
+		if err := loaded.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if store.schemaLookups != 1 {
+		t.Errorf("saving a node without schema looked up its schema %d times, expected once", store.schemaLookups)
+	}
+}
+
+func TestRequirePropertiesStorageError(t *testing.T) {
+	store := &schemaStore{Store: memstore.New("shard1")}
+
+	schema, err := zoom.NewSchema(store, "person", "person-required")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := zoom.NewNode(store, "")
+	n.SetString("FirstName", "Donald")
+	n.SetString("LastName", "Duck")
+	if err := n.SetSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	store.failProps = n.ID()
+	update := zoom.NewNode(store, n.ID())
+	update.SetInt("Age", 44)
+
+	var verr *zoom.ValidationError
+	if err := update.Save(); !errors.As(err, &verr) || verr.Err != errStorage {
+		t.Errorf("Save() = %v, expected the storage error", err)
+	}
+}
@@ -1,5 +1,7 @@
 package zoom
 
+import "io"
+
 /*
 	props map[string]interface{}   // saved in nodes file
 	texts map[string]string        // saved in each file for a text (text is string lenghth > 255) texts are always UTF-8, \n
@@ -86,17 +88,36 @@ type Transaction interface {
 	// map relname => nodeUuid, only the texts that have a key set are going to be changed
 	SaveNodeTexts(uuid string, texts map[string]string) error
 
-	// map poolname => []nodeUuid, only the blobs that have a key set are going to be changed
-	// SaveNodeBlobs(uuid string, blobs map[string]io.Reader) error
+	// map blobname => content, only the blobs that have a key set are going to be changed
+	SaveNodeBlobs(uuid string, blobs map[string]io.Reader) error
 
+	// map "shard-toUUID" => edge value (see FormatEdgeValue)
 	SaveEdges(category, fromUUID string, edges map[string]string) error
 
+	// removes the edges with their property nodes and the edges from the incoming edges of their
+	// targets in the same shard. the incoming edges of targets in other shards are saved in the stores
+	// of their shards and must be removed via Edge.Remove
 	RemoveEdges(category, fromUUID string) error
 
 	GetEdges(category, fromUUID string) (edges map[string]string, err error)
 
-	// remove node with properties, relations and pools
-	// references are not checked nor deleted, cascading deletes must be made from the outside
+	// returns the sorted categories in which the node has outgoing edges
+	GetEdgeCategories(fromUUID string) (categories []string, err error)
+
+	// the incoming edges of a node are the reverse of the edges, saved under the target node
+	// map "shard-fromUUID" => edge value (see FormatEdgeValue), they are maintained by Edge and Node
+	// and by RemoveEdges and RemoveNode for edges inside the shard
+	SaveIncomingEdges(category, toUUID string, edges map[string]string) error
+
+	// does not remove the property nodes, since they belong to the shard of the edge
+	RemoveIncomingEdges(category, toUUID string) error
+
+	GetIncomingEdges(category, toUUID string) (edges map[string]string, err error)
+
+	// remove node with properties, texts, blobs, edges and incoming edges
+	// the edges of the node are removed from the incoming edges of their targets like in RemoveEdges,
+	// so targets in other shards keep them (see Resolver.RemoveNode)
+	// references are not checked nor deleted, cascading deletes must be made from the outside (see Integrity)
 	RemoveNode(uuid string) error
 
 	// only the properties that exist make it into the returned map
@@ -114,15 +135,18 @@ type Transaction interface {
 	// there must be wrappers put around the store to ensure this (preferably by using indices)
 	GetNodeTexts(uuid string, requestedTexts []string) (texts map[string]string, err error)
 
-	// the returned map has as values slices of uuids of the nodes
-	// only the pools that exist make it into the returned map
-	// it is no error if a requested pool does not exist for a node
-	// the caller has to check the returned map against the requested pools if
-	// she wants to check, if all requested pools have been returned
-	// also there is no guarantee that the nodes which uuids are returned do still exist.
-	// there must be wrappers put around the store to ensure this (preferably by using indices)
-	// GetNodeBlobs(uuid string,  requestedBlobs []string) (pools map[string]io.Reader, err error)
-	// GetNodeBlobs(uuid string, requestedBlobs []string, fn func(string, io.Reader) error) error
+	// fn is called for each requested blob that exists, with the name and the content of the blob
+	// the reader is only valid during the call of fn
+	// it is no error if a requested blob does not exist for a node
+	GetNodeBlobs(uuid string, requestedBlobs []string, fn func(string, io.Reader) error) error
+
+	// fn is called for the uuid of each node of the shard that has a properties file and which uuid
+	// starts with the given prefix (all nodes if prefix is empty), in the order of the uuids.
+	// the prefix is compared literally, it is no pattern.
+	// the property nodes of edges and the schema nodes of the shard are nodes too and are part of the scan.
+	// if fn returns StopScan, the scan ends without error, any other error ends the scan and is returned
+	ScanNodes(prefix string, fn func(uuid string) error) error
+
 	Shard() string
 }
 
@@ -0,0 +1,784 @@
+// Package storetest checks that an implementation of zoom.Store fulfills the contract
+// that is documented on zoom.Transaction and zoom.Store.
+//
+// A backend runs the suite from its own tests, e.g.
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func() zoom.Store { return memstore.New("shard1") })
+//	}
+//
+// newStore must return a fresh and empty store for every call. Stores that are only valid inside
+// a transaction of the backend run the Tests one by one inside their transaction instead.
+package storetest
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metakeule/zoom"
+)
+
+// Test is a single check of the suite
+type Test struct {
+	Name string
+	Fn   func(t *testing.T, st zoom.Store)
+}
+
+// Tests are the checks that Run runs against a store
+var Tests = []Test{
+	{"SaveOnlySetProperties", testSaveOnlySetProperties},
+	{"NilDeletesProperty", testNilDeletesProperty},
+	{"MissingPropertiesLeftOut", testMissingPropertiesLeftOut},
+	{"PropertyTypes", testPropertyTypes},
+	{"SaveOnlySetTexts", testSaveOnlySetTexts},
+	{"MissingTextsLeftOut", testMissingTextsLeftOut},
+	{"SaveAndGetBlobs", testSaveAndGetBlobs},
+	{"GetEdgesMissingFile", testGetEdgesMissingFile},
+	{"SaveAndGetEdges", testSaveAndGetEdges},
+	{"RemoveEdgesMissingFile", testRemoveEdgesMissingFile},
+	{"RemoveEdgesRemovesPropertyNodes", testRemoveEdgesRemovesPropertyNodes},
+	{"GetEdgeCategories", testGetEdgeCategories},
+	{"SaveAndGetIncomingEdges", testSaveAndGetIncomingEdges},
+	{"RemoveIncomingEdgesKeepsPropertyNodes", testRemoveIncomingEdgesKeepsPropertyNodes},
+	{"RemoveNodeRemovesIncomingEdges", testRemoveNodeRemovesIncomingEdges},
+	{"RemoveEdgesUpdatesIncomingEdges", testRemoveEdgesUpdatesIncomingEdges},
+	{"RemoveNodeUpdatesIncomingEdges", testRemoveNodeUpdatesIncomingEdges},
+	{"RemoveNode", testRemoveNode},
+	{"BatchLoader", testBatchLoader},
+	{"ScanNodes", testScanNodes},
+	{"ReadStagedChanges", testReadStagedChanges},
+	{"Commit", testCommit},
+	{"Rollback", testRollback},
+	{"RollbackBlobs", testRollbackBlobs},
+	{"NewTransactionRollsBackOnError", testNewTransactionRollsBackOnError},
+	{"NewTransactionNoCommit", testNewTransactionNoCommit},
+}
+
+// Run runs all Tests as subtests of t, each on a store returned by newStore
+func Run(t *testing.T, newStore func() zoom.Store) {
+	for _, test := range Tests {
+		fn := test.Fn
+		t.Run(test.Name, func(t *testing.T) {
+			fn(t, newStore())
+		})
+	}
+}
+
+const (
+	uuid1 = "0a8f2ad5-9e02-4c3a-8f0e-8f1a7c2d6b01"
+	uuid2 = "1b9f3be6-af13-4d4b-9f1f-9f2b8d3e7c02"
+	uuid3 = "2c0a4cf7-b024-4e5c-a02a-a03c9e4f8d03"
+)
+
+var msg = zoom.CommitMessage{App: "storetest", Command: "test"}
+
+func commit(t *testing.T, st zoom.Store) {
+	if err := st.Commit(msg); err != nil {
+		t.Fatalf("Commit() returned error: %s", err)
+	}
+}
+
+func saveProps(t *testing.T, st zoom.Store, uuid string, props map[string]interface{}) {
+	if err := st.SaveNodeProperties(uuid, props); err != nil {
+		t.Fatalf("SaveNodeProperties(%#v, %#v) returned error: %s", uuid, props, err)
+	}
+}
+
+func getProps(t *testing.T, st zoom.Store, uuid string, requested ...string) map[string]interface{} {
+	props, err := st.GetNodeProperties(uuid, requested)
+	if err != nil {
+		t.Fatalf("GetNodeProperties(%#v, %#v) returned error: %s", uuid, requested, err)
+	}
+	return props
+}
+
+func getTexts(t *testing.T, st zoom.Store, uuid string, requested ...string) map[string]string {
+	texts, err := st.GetNodeTexts(uuid, requested)
+	if err != nil {
+		t.Fatalf("GetNodeTexts(%#v, %#v) returned error: %s", uuid, requested, err)
+	}
+	return texts
+}
+
+func getEdges(t *testing.T, st zoom.Store, category, uuid string) map[string]string {
+	edges, err := st.GetEdges(category, uuid)
+	if err != nil {
+		t.Fatalf("GetEdges(%#v, %#v) returned error: %s", category, uuid, err)
+	}
+	if edges == nil {
+		t.Fatalf("GetEdges(%#v, %#v) returned nil map", category, uuid)
+	}
+	return edges
+}
+
+func hasNode(st zoom.Store, uuid string, requested ...string) bool {
+	props, err := st.GetNodeProperties(uuid, requested)
+	return err == nil && len(props) > 0
+}
+
+func keys(m map[string]interface{}) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func testSaveOnlySetProperties(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald", "LastName": "Duck"})
+	commit(t, st)
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Daisy"})
+	commit(t, st)
+
+	got := getProps(t, st, uuid1, "FirstName", "LastName")
+	expected := map[string]interface{}{"FirstName": "Daisy", "LastName": "Duck"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeProperties() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testNilDeletesProperty(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald", "LastName": "Duck"})
+	commit(t, st)
+	saveProps(t, st, uuid1, map[string]interface{}{"LastName": nil})
+	commit(t, st)
+
+	got := getProps(t, st, uuid1, "FirstName", "LastName")
+
+	if ks := keys(got); !reflect.DeepEqual(ks, []string{"FirstName"}) {
+		t.Errorf("keys of GetNodeProperties() = %#v, expected %#v", ks, []string{"FirstName"})
+	}
+}
+
+func testMissingPropertiesLeftOut(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	commit(t, st)
+
+	got := getProps(t, st, uuid1, "FirstName", "Missing")
+
+	if ks := keys(got); !reflect.DeepEqual(ks, []string{"FirstName"}) {
+		t.Errorf("keys of GetNodeProperties() = %#v, expected %#v", ks, []string{"FirstName"})
+	}
+}
+
+func testPropertyTypes(t *testing.T, st zoom.Store) {
+	born := time.Date(1971, 3, 9, 12, 30, 0, 0, time.UTC)
+	props := map[string]interface{}{
+		"Age":       int64(44),
+		"Height":    float64(1.82),
+		"Married":   false,
+		"FirstName": "Donald",
+		"Born":      born,
+	}
+	saveProps(t, st, uuid1, props)
+	commit(t, st)
+
+	got := getProps(t, st, uuid1, "Age", "Height", "Married", "FirstName", "Born")
+
+	if tm, ok := got["Born"].(time.Time); !ok || !tm.Equal(born) {
+		t.Errorf("GetNodeProperties()[\"Born\"] = %#v, expected %#v", got["Born"], born)
+	}
+
+	delete(got, "Born")
+	delete(props, "Born")
+
+	if !reflect.DeepEqual(got, props) {
+		t.Errorf("GetNodeProperties() = %#v, expected %#v", got, props)
+	}
+}
+
+func testSaveOnlySetTexts(t *testing.T, st zoom.Store) {
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a duck", "Notes": "none"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a famous duck"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	got := getTexts(t, st, uuid1, "Bio", "Notes")
+	expected := map[string]string{"Bio": "a famous duck", "Notes": "none"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeTexts() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testMissingTextsLeftOut(t *testing.T, st zoom.Store) {
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a duck"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	got := getTexts(t, st, uuid1, "Bio", "Missing")
+	expected := map[string]string{"Bio": "a duck"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeTexts() = %#v, expected %#v", got, expected)
+	}
+}
+
+func getBlobs(t *testing.T, st zoom.Store, uuid string, requested ...string) map[string]string {
+	blobs := map[string]string{}
+	err := st.GetNodeBlobs(uuid, requested, func(name string, rd io.Reader) error {
+		b, err := ioutil.ReadAll(rd)
+		blobs[name] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetNodeBlobs(%#v, %#v) returned error: %s", uuid, requested, err)
+	}
+	return blobs
+}
+
+func testSaveAndGetBlobs(t *testing.T, st zoom.Store) {
+	err := st.SaveNodeBlobs(uuid1, map[string]io.Reader{
+		"image/png/avatar": strings.NewReader("PNG"),
+		"text/plain/notes": strings.NewReader("some notes"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	got := getBlobs(t, st, uuid1, "image/png/avatar", "text/plain/notes", "missing")
+	expected := map[string]string{"image/png/avatar": "PNG", "text/plain/notes": "some notes"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeBlobs() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testGetEdgesMissingFile(t *testing.T, st zoom.Store) {
+	if edges := getEdges(t, st, "knows", uuid1); len(edges) != 0 {
+		t.Errorf("GetEdges() = %#v, expected empty map", edges)
+	}
+}
+
+func testSaveAndGetEdges(t *testing.T, st zoom.Store) {
+	edges := map[string]string{
+		st.Shard() + "-" + uuid2: uuid3,
+		st.Shard() + "-" + uuid3: "",
+	}
+
+	if err := st.SaveEdges("knows", uuid1, edges); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getEdges(t, st, "knows", uuid1); !reflect.DeepEqual(got, edges) {
+		t.Errorf("GetEdges() = %#v, expected %#v", got, edges)
+	}
+}
+
+func testGetEdgeCategories(t *testing.T, st zoom.Store) {
+	cats, err := st.GetEdgeCategories(uuid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cats) != 0 {
+		t.Errorf("GetEdgeCategories() without edges = %#v, expected none", cats)
+	}
+
+	for _, cat := range []string{"works", "knows"} {
+		if err := st.SaveEdges(cat, uuid1, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// edges of other nodes and incoming edges don't count
+	if err := st.SaveEdges("likes", uuid2, map[string]string{st.Shard() + "-" + uuid1: ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveIncomingEdges("hates", uuid1, map[string]string{st.Shard() + "-" + uuid3: ""}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	cats, err = st.GetEdgeCategories(uuid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"knows", "works"}; !reflect.DeepEqual(cats, expected) {
+		t.Errorf("GetEdgeCategories() = %#v, expected %#v", cats, expected)
+	}
+}
+
+func getIncomingEdges(t *testing.T, st zoom.Store, category, uuid string) map[string]string {
+	edges, err := st.GetIncomingEdges(category, uuid)
+	if err != nil {
+		t.Fatalf("GetIncomingEdges(%#v, %#v) returned error: %s", category, uuid, err)
+	}
+	if edges == nil {
+		t.Fatalf("GetIncomingEdges(%#v, %#v) returned nil map", category, uuid)
+	}
+	return edges
+}
+
+func testSaveAndGetIncomingEdges(t *testing.T, st zoom.Store) {
+	if edges := getIncomingEdges(t, st, "knows", uuid1); len(edges) != 0 {
+		t.Errorf("GetIncomingEdges() = %#v, expected empty map", edges)
+	}
+
+	edges := map[string]string{
+		st.Shard() + "-" + uuid2: uuid3,
+		"othershard-" + uuid3:    "",
+	}
+
+	if err := st.SaveIncomingEdges("knows", uuid1, edges); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getIncomingEdges(t, st, "knows", uuid1); !reflect.DeepEqual(got, edges) {
+		t.Errorf("GetIncomingEdges() = %#v, expected %#v", got, edges)
+	}
+
+	if got := getEdges(t, st, "knows", uuid1); len(got) != 0 {
+		t.Errorf("GetEdges() = %#v, expected empty map", got)
+	}
+}
+
+func testRemoveIncomingEdgesKeepsPropertyNodes(t *testing.T, st zoom.Store) {
+	if err := st.RemoveIncomingEdges("knows", uuid1); err != nil {
+		t.Errorf("RemoveIncomingEdges() on missing file returned error: %s", err)
+	}
+
+	saveProps(t, st, uuid3, map[string]interface{}{"Since": "2015"})
+	if err := st.SaveIncomingEdges("knows", uuid1, map[string]string{st.Shard() + "-" + uuid2: uuid3}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if err := st.RemoveIncomingEdges("knows", uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getIncomingEdges(t, st, "knows", uuid1); len(got) != 0 {
+		t.Errorf("GetIncomingEdges() after RemoveIncomingEdges() = %#v, expected empty map", got)
+	}
+
+	if !hasNode(st, uuid3, "Since") {
+		t.Errorf("property node has been removed by RemoveIncomingEdges()")
+	}
+}
+
+func testRemoveNodeRemovesIncomingEdges(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	if err := st.SaveIncomingEdges("knows", uuid1, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveIncomingEdges("knows", uuid2, map[string]string{st.Shard() + "-" + uuid1: ""}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if err := st.RemoveNode(uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getIncomingEdges(t, st, "knows", uuid1); len(got) != 0 {
+		t.Errorf("GetIncomingEdges() after RemoveNode() = %#v, expected empty map", got)
+	}
+
+	if got := getIncomingEdges(t, st, "knows", uuid2); len(got) != 1 {
+		t.Errorf("incoming edges of other node have been removed: %#v", got)
+	}
+}
+
+// saveEdgesWithIncoming saves the edges of uuid1 to uuid2 and to a node of another shard
+// together with the incoming edges of uuid2, that also has an incoming edge from uuid3
+func saveEdgesWithIncoming(t *testing.T, st zoom.Store) {
+	edges := map[string]string{
+		st.Shard() + "-" + uuid2:               "",
+		st.Shard() + "-" + uuid2 + "#" + uuid3: "",
+		"othershard-" + uuid3:                  "",
+	}
+	if err := st.SaveEdges("knows", uuid1, edges); err != nil {
+		t.Fatal(err)
+	}
+	incoming := map[string]string{
+		st.Shard() + "-" + uuid1:               "",
+		st.Shard() + "-" + uuid1 + "#" + uuid3: "",
+		st.Shard() + "-" + uuid3:               "",
+	}
+	if err := st.SaveIncomingEdges("knows", uuid2, incoming); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+}
+
+func testRemoveEdgesUpdatesIncomingEdges(t *testing.T, st zoom.Store) {
+	saveEdgesWithIncoming(t, st)
+
+	if err := st.RemoveEdges("knows", uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	expected := map[string]string{st.Shard() + "-" + uuid3: ""}
+	if got := getIncomingEdges(t, st, "knows", uuid2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetIncomingEdges() after RemoveEdges() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testRemoveNodeUpdatesIncomingEdges(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	saveEdgesWithIncoming(t, st)
+
+	if err := st.RemoveNode(uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	expected := map[string]string{st.Shard() + "-" + uuid3: ""}
+	if got := getIncomingEdges(t, st, "knows", uuid2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetIncomingEdges() after RemoveNode() = %#v, expected %#v", got, expected)
+	}
+
+	// the last incoming edge removes the file
+	if err := st.SaveEdges("knows", uuid3, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if err := st.RemoveNode(uuid3); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getIncomingEdges(t, st, "knows", uuid2); len(got) != 0 {
+		t.Errorf("GetIncomingEdges() after removing all sources = %#v, expected empty map", got)
+	}
+}
+
+func testRemoveEdgesMissingFile(t *testing.T, st zoom.Store) {
+	if err := st.RemoveEdges("knows", uuid1); err != nil {
+		t.Errorf("RemoveEdges() on missing edges file returned error: %s", err)
+	}
+}
+
+func testRemoveEdgesRemovesPropertyNodes(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid3, map[string]interface{}{"Since": "2015"})
+	edges := map[string]string{
+		st.Shard() + "-" + uuid2: uuid3,
+		st.Shard() + "-" + uuid1: "",
+	}
+	if err := st.SaveEdges("knows", uuid1, edges); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if err := st.RemoveEdges("knows", uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if got := getEdges(t, st, "knows", uuid1); len(got) != 0 {
+		t.Errorf("GetEdges() after RemoveEdges() = %#v, expected empty map", got)
+	}
+
+	if hasNode(st, uuid3, "Since") {
+		t.Errorf("property node of removed edge still exists")
+	}
+}
+
+func testRemoveNode(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a duck"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveEdges("knows", uuid1, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveEdges("likes", uuid1, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveNodeBlobs(uuid1, map[string]io.Reader{"image/png/avatar": strings.NewReader("PNG")}); err != nil {
+		t.Fatal(err)
+	}
+	saveProps(t, st, uuid2, map[string]interface{}{"FirstName": "Daisy"})
+	commit(t, st)
+
+	if err := st.RemoveNode(uuid1); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if hasNode(st, uuid1, "FirstName") {
+		t.Errorf("properties of removed node still exist")
+	}
+
+	if got := getTexts(t, st, uuid1, "Bio"); len(got) != 0 {
+		t.Errorf("texts of removed node still exist: %#v", got)
+	}
+
+	if got := getBlobs(t, st, uuid1, "image/png/avatar"); len(got) != 0 {
+		t.Errorf("blobs of removed node still exist: %#v", got)
+	}
+
+	for _, category := range []string{"knows", "likes"} {
+		if got := getEdges(t, st, category, uuid1); len(got) != 0 {
+			t.Errorf("%s edges of removed node still exist: %#v", category, got)
+		}
+	}
+
+	if !hasNode(st, uuid2, "FirstName") {
+		t.Errorf("target node of removed node has been removed")
+	}
+}
+
+func testReadStagedChanges(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+
+	got := getProps(t, st, uuid1, "FirstName")
+	expected := map[string]interface{}{"FirstName": "Donald"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeProperties() before Commit() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testCommit(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	commit(t, st)
+
+	// a rollback after a commit must not discard the committed changes
+	if err := st.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasNode(st, uuid1, "FirstName") {
+		t.Errorf("committed node is gone after Rollback()")
+	}
+}
+
+func testRollback(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	commit(t, st)
+
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Daisy"})
+	saveProps(t, st, uuid2, map[string]interface{}{"FirstName": "Mickey"})
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a duck"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveEdges("knows", uuid1, map[string]string{st.Shard() + "-" + uuid2: ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := getProps(t, st, uuid1, "FirstName")
+	expected := map[string]interface{}{"FirstName": "Donald"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeProperties() after Rollback() = %#v, expected %#v", got, expected)
+	}
+
+	if hasNode(st, uuid2, "FirstName") {
+		t.Errorf("node created before Rollback() still exists")
+	}
+
+	if got := getTexts(t, st, uuid1, "Bio"); len(got) != 0 {
+		t.Errorf("texts saved before Rollback() still exist: %#v", got)
+	}
+
+	if got := getEdges(t, st, "knows", uuid1); len(got) != 0 {
+		t.Errorf("edges saved before Rollback() still exist: %#v", got)
+	}
+}
+
+func testRollbackBlobs(t *testing.T, st zoom.Store) {
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald"})
+	if err := st.SaveNodeBlobs(uuid1, map[string]io.Reader{"image/png/avatar": strings.NewReader("PNG")}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if err := st.SaveNodeBlobs(uuid2, map[string]io.Reader{"text/plain/notes": strings.NewReader("new")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RemoveNode(uuid1); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveNodeBlobs(uuid1, map[string]io.Reader{"text/plain/notes": strings.NewReader("notes")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// inside the transaction the old blobs are gone and the new ones are there
+	if got := getBlobs(t, st, uuid1, "image/png/avatar", "text/plain/notes"); !reflect.DeepEqual(got, map[string]string{"text/plain/notes": "notes"}) {
+		t.Errorf("GetNodeBlobs() before Rollback() = %#v", got)
+	}
+
+	if err := st.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"image/png/avatar": "PNG"}
+	if got := getBlobs(t, st, uuid1, "image/png/avatar", "text/plain/notes"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeBlobs() after Rollback() = %#v, expected %#v", got, expected)
+	}
+
+	if got := getBlobs(t, st, uuid2, "text/plain/notes"); len(got) != 0 {
+		t.Errorf("blobs saved before Rollback() still exist: %#v", got)
+	}
+
+	// replacing a blob is committed
+	if err := st.SaveNodeBlobs(uuid1, map[string]io.Reader{"image/png/avatar": strings.NewReader("PNG2")}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	expected = map[string]string{"image/png/avatar": "PNG2"}
+	if got := getBlobs(t, st, uuid1, "image/png/avatar"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetNodeBlobs() after Commit() = %#v, expected %#v", got, expected)
+	}
+}
+
+func testNewTransactionRollsBackOnError(t *testing.T, st zoom.Store) {
+	errFailed := errors.New("failed")
+
+	err := zoom.NewTransaction(st, msg, func(tr zoom.Transaction) error {
+		if err := tr.SaveNodeProperties(uuid1, map[string]interface{}{"FirstName": "Donald"}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+
+	if err != errFailed {
+		t.Fatalf("NewTransaction() returned %v, expected %v", err, errFailed)
+	}
+
+	if hasNode(st, uuid1, "FirstName") {
+		t.Errorf("node saved in failed transaction exists")
+	}
+}
+
+func testNewTransactionNoCommit(t *testing.T, st zoom.Store) {
+	err := zoom.NewTransaction(st, msg, func(tr zoom.Transaction) error {
+		if err := tr.SaveNodeProperties(uuid1, map[string]interface{}{"FirstName": "Donald"}); err != nil {
+			return err
+		}
+		return zoom.ErrNoCommit
+	})
+
+	if err != nil {
+		t.Fatalf("NewTransaction() returned %v, expected nil", err)
+	}
+
+	if hasNode(st, uuid1, "FirstName") {
+		t.Errorf("node saved in transaction with ErrNoCommit exists")
+	}
+}
+
+func testBatchLoader(t *testing.T, st zoom.Store) {
+	bl, ok := st.(zoom.BatchLoader)
+	if !ok {
+		t.Skip("store is no zoom.BatchLoader")
+	}
+
+	saveProps(t, st, uuid1, map[string]interface{}{"FirstName": "Donald", "LastName": "Duck"})
+	saveProps(t, st, uuid2, map[string]interface{}{"FirstName": "Daisy"})
+	if err := st.SaveNodeTexts(uuid1, map[string]string{"Bio": "a duck"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	props, err := bl.GetNodesProperties([]string{uuid1, uuid2, uuid3}, []string{"FirstName"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[string]interface{}{
+		uuid1: {"FirstName": "Donald"},
+		uuid2: {"FirstName": "Daisy"},
+	}
+
+	if !reflect.DeepEqual(props, expected) {
+		t.Errorf("GetNodesProperties() = %#v, expected %#v", props, expected)
+	}
+
+	texts, err := bl.GetNodesTexts([]string{uuid1, uuid2}, []string{"Bio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if texts[uuid1]["Bio"] != "a duck" || len(texts[uuid2]) != 0 {
+		t.Errorf("GetNodesTexts() = %#v", texts)
+	}
+}
+
+func scanNodes(t *testing.T, st zoom.Store, prefix string, max int) []string {
+	uuids := []string{}
+	err := st.ScanNodes(prefix, func(uuid string) error {
+		uuids = append(uuids, uuid)
+		if len(uuids) == max {
+			return zoom.StopScan
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uuids
+}
+
+func testScanNodes(t *testing.T, st zoom.Store) {
+	if uuids := scanNodes(t, st, "", -1); len(uuids) != 0 {
+		t.Errorf("ScanNodes() on empty store = %#v, expected none", uuids)
+	}
+
+	for _, uuid := range []string{uuid3, uuid1, uuid2} {
+		saveProps(t, st, uuid, map[string]interface{}{"Name": "x"})
+	}
+	// texts and edges alone are no nodes
+	if err := st.SaveNodeTexts("3d1b5d08-c135-4f6d-b13b-b14daf5a9e04", map[string]string{"Body": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, st)
+
+	if uuids, expected := scanNodes(t, st, "", -1), []string{uuid1, uuid2, uuid3}; !reflect.DeepEqual(uuids, expected) {
+		t.Errorf("ScanNodes() = %#v, expected %#v", uuids, expected)
+	}
+
+	for prefix, expected := range map[string][]string{
+		"1":       {uuid2},
+		"1b9f":    {uuid2},
+		uuid3:     {uuid3},
+		"0a8f2b":  {},
+		"4":       {},
+		"2c0a4cf": {uuid3},
+		// glob characters are no wildcards
+		"*":       {},
+		"1b9f*":   {},
+		"0a8f?ad": {},
+		"[01]":    {},
+	} {
+		if uuids := scanNodes(t, st, prefix, -1); !reflect.DeepEqual(uuids, expected) {
+			t.Errorf("ScanNodes(%#v) = %#v, expected %#v", prefix, uuids, expected)
+		}
+	}
+
+	if uuids, expected := scanNodes(t, st, "", 2), []string{uuid1, uuid2}; !reflect.DeepEqual(uuids, expected) {
+		t.Errorf("ScanNodes() stopped after 2 = %#v, expected %#v", uuids, expected)
+	}
+
+	failed := errors.New("failed")
+	err := st.ScanNodes("", func(string) error { return failed })
+	if err != failed {
+		t.Errorf("ScanNodes() returned %v, expected the error of fn", err)
+	}
+}
@@ -15,6 +15,8 @@ var ErrNoCommit = errors.New("do not commit")
 // the transaction did not complete and the rollback wasn't successfull either
 // func (s *Shard) Transaction(comment string, actions ...func(Store) error) (rolledback bool, err error) {
 func NewTransaction(st Store, comment CommitMessage, action func(t Transaction) error) (err error) {
+	// wrapped stores like the integrity store make their changes via the outermost store
+	bindOuter(st)
 	// for _, a := range actions {
 	err = action(st)
 	if err == ErrNoCommit {
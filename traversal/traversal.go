@@ -0,0 +1,202 @@
+// Package traversal walks the graph of nodes along their edges.
+package traversal
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/metakeule/zoom"
+)
+
+/*
+	All functions follow the edges of the given categories via Transaction.GetEdges. Only edges to nodes of
+	the shard of the start node are followed, unless a Resolver is given. Each node is visited at most once,
+	so cycles are no problem.
+*/
+
+// Stop may be returned by a VisitFunc to end the traversal without error
+var Stop = errors.New("stop traversal")
+
+// ErrNoPath is returned by ShortestPath, if the target can't be reached
+var ErrNoPath = errors.New("no path")
+
+// VisitFunc is called for each visited node. via is the edge by which the node has been reached
+// (nil for the start node) and depth is the number of edges from the start node.
+// If it returns Stop, the traversal ends without error, any other error ends the traversal
+// and is returned.
+type VisitFunc func(n *zoom.Node, via *zoom.Edge, depth int) error
+
+// Options configure a traversal
+type Options struct {
+	// the edge categories that are followed
+	Categories []string
+
+	// the maximal number of edges from the start node, 0 means no limit
+	MaxDepth int
+
+	// Filter is called for each edge before it is followed, edges for which it returns false are skipped.
+	// depth is the depth of the target node. If Filter is nil, all edges are followed.
+	Filter func(e *zoom.Edge, depth int) bool
+
+	// Resolver is used to follow edges to nodes of other shards. If it is nil, only the edges to
+	// nodes of the same shard are followed.
+	Resolver *zoom.Resolver
+}
+
+func key(n *zoom.Node) string {
+	return n.Shard() + "-" + n.ID()
+}
+
+// edges returns the edges of n that should be followed, sorted by category, target and edge id
+func (o Options) edges(n *zoom.Node, depth int) ([]*zoom.Edge, error) {
+	var res []*zoom.Edge
+	for _, cat := range o.Categories {
+		var edges []*zoom.Edge
+		var err error
+		if o.Resolver != nil {
+			edges, err = o.Resolver.Edges(n, cat)
+		} else {
+			edges, err = n.GetEdges(n.Transaction, cat)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(edges, func(i, j int) bool {
+			ki, kj := key(edges[i].To), key(edges[j].To)
+			if ki != kj {
+				return ki < kj
+			}
+			// parallel edges to the same target
+			return edges[i].ID < edges[j].ID
+		})
+		for _, e := range edges {
+			if o.Filter == nil || o.Filter(e, depth) {
+				res = append(res, e)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (o Options) tooDeep(depth int) bool {
+	return o.MaxDepth > 0 && depth > o.MaxDepth
+}
+
+type step struct {
+	node  *zoom.Node
+	via   *zoom.Edge
+	depth int
+}
+
+// BFS visits the nodes reachable from the start node breadth first
+func BFS(from *zoom.Node, opts Options, fn VisitFunc) error {
+	visited := map[string]bool{key(from): true}
+	queue := []step{{node: from}}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		if err := fn(s.node, s.via, s.depth); err != nil {
+			if err == Stop {
+				return nil
+			}
+			return err
+		}
+
+		if opts.tooDeep(s.depth + 1) {
+			continue
+		}
+
+		edges, err := opts.edges(s.node, s.depth+1)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range edges {
+			if visited[key(e.To)] {
+				continue
+			}
+			visited[key(e.To)] = true
+			queue = append(queue, step{node: e.To, via: e, depth: s.depth + 1})
+		}
+	}
+	return nil
+}
+
+// DFS visits the nodes reachable from the start node depth first
+func DFS(from *zoom.Node, opts Options, fn VisitFunc) error {
+	err := dfs(from, nil, 0, opts, map[string]bool{}, fn)
+	if err == Stop {
+		return nil
+	}
+	return err
+}
+
+func dfs(n *zoom.Node, via *zoom.Edge, depth int, opts Options, visited map[string]bool, fn VisitFunc) error {
+	visited[key(n)] = true
+
+	if err := fn(n, via, depth); err != nil {
+		return err
+	}
+
+	if opts.tooDeep(depth + 1) {
+		return nil
+	}
+
+	edges, err := opts.edges(n, depth+1)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range edges {
+		if visited[key(e.To)] {
+			continue
+		}
+		if err := dfs(e.To, e, depth+1, opts, visited, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShortestPath returns the edges of a path with the least number of hops from one node to another,
+// following the edges of the given categories. If from and to are the same node, the path is empty.
+// If there is no path, ErrNoPath is returned.
+func ShortestPath(from, to *zoom.Node, categories ...string) ([]*zoom.Edge, error) {
+	return ShortestPathWith(from, to, Options{Categories: categories})
+}
+
+// ShortestPathWith is like ShortestPath, but respects MaxDepth and Filter of the given options
+func ShortestPathWith(from, to *zoom.Node, opts Options) ([]*zoom.Edge, error) {
+	target := key(to)
+	via := map[string]*zoom.Edge{}
+	var found bool
+
+	err := BFS(from, opts, func(n *zoom.Node, e *zoom.Edge, depth int) error {
+		if e != nil {
+			via[key(n)] = e
+		}
+		if key(n) == target {
+			found = true
+			return Stop
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrNoPath
+	}
+
+	path := []*zoom.Edge{}
+	for k := target; k != key(from); {
+		e := via[k]
+		path = append([]*zoom.Edge{e}, path...)
+		k = key(e.From)
+	}
+	return path, nil
+}
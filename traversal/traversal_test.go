@@ -0,0 +1,239 @@
+package traversal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+// graph creates the nodes a..f with the edges
+//
+//	a -knows-> b -knows-> c -knows-> a (cycle)
+//	a -works-> d -knows-> e
+//	c -knows-> e -knows-> f
+func graph(t *testing.T) map[string]*zoom.Node {
+	store := memstore.New("shard1")
+	nodes := map[string]*zoom.Node{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		n := zoom.NewNode(store, "")
+		n.SetString("name", name)
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = n
+	}
+
+	edges := []struct{ from, category, to string }{
+		{"a", "knows", "b"},
+		{"b", "knows", "c"},
+		{"c", "knows", "a"},
+		{"a", "works", "d"},
+		{"d", "knows", "e"},
+		{"c", "knows", "e"},
+		{"e", "knows", "f"},
+	}
+
+	for _, e := range edges {
+		if err := nodes[e.from].NewEdge(e.category, nodes[e.to], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nodes
+}
+
+func names(t *testing.T, nodes []*zoom.Node) []string {
+	var res []string
+	for _, n := range nodes {
+		if err := n.LoadProperties([]string{"name"}); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, n.GetString("name"))
+	}
+	return res
+}
+
+func collect(t *testing.T, walk func(*zoom.Node, Options, VisitFunc) error, from *zoom.Node, opts Options) []string {
+	var visited []*zoom.Node
+	err := walk(from, opts, func(n *zoom.Node, via *zoom.Edge, depth int) error {
+		visited = append(visited, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names(t, visited)
+}
+
+func TestBFS(t *testing.T) {
+	nodes := graph(t)
+
+	got := collect(t, BFS, nodes["a"], Options{Categories: []string{"knows", "works"}})
+	if len(got) != 6 || got[0] != "a" {
+		t.Fatalf("BFS visited %v, expected all 6 nodes starting with a", got)
+	}
+
+	// b and d are at depth 1, c and e at depth 2, f at depth 3
+	depths := map[string]int{}
+	err := BFS(nodes["a"], Options{Categories: []string{"knows", "works"}}, func(n *zoom.Node, via *zoom.Edge, depth int) error {
+		depths[names(t, []*zoom.Node{n})[0]] = depth
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"a": 0, "b": 1, "d": 1, "c": 2, "e": 2, "f": 3}
+	if !reflect.DeepEqual(depths, expected) {
+		t.Errorf("BFS depths = %v, expected %v", depths, expected)
+	}
+
+	got = collect(t, BFS, nodes["a"], Options{Categories: []string{"knows"}, MaxDepth: 1})
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("BFS with MaxDepth 1 visited %v, expected %v", got, []string{"a", "b"})
+	}
+}
+
+func TestDFS(t *testing.T) {
+	nodes := graph(t)
+
+	got := collect(t, DFS, nodes["a"], Options{Categories: []string{"knows"}})
+	if len(got) != 5 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("DFS visited %v, expected a, b, c first and 5 nodes", got)
+	}
+
+	var count int
+	err := DFS(nodes["a"], Options{Categories: []string{"knows"}}, func(n *zoom.Node, via *zoom.Edge, depth int) error {
+		count++
+		if count == 2 {
+			return Stop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("DFS did not stop, visited %d nodes", count)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	nodes := graph(t)
+
+	opts := Options{
+		Categories: []string{"knows", "works"},
+		Filter: func(e *zoom.Edge, depth int) bool {
+			return e.Category == "works" || depth > 1
+		},
+	}
+
+	got := collect(t, BFS, nodes["a"], opts)
+	if !reflect.DeepEqual(got, []string{"a", "d", "e", "f"}) {
+		t.Errorf("BFS with filter visited %v, expected %v", got, []string{"a", "d", "e", "f"})
+	}
+}
+
+func TestParallelEdgesOrder(t *testing.T) {
+	nodes := graph(t)
+
+	var ids []string
+	// the last added edge comes first
+	for i := 0; i < 5; i++ {
+		e, err := nodes["f"].AddEdgeAt("visited", nodes["a"], 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+	sort.Strings(ids)
+
+	// the parallel edges to the same target are followed in the order of their ids, not their positions
+	var got []string
+	opts := Options{
+		Categories: []string{"visited"},
+		Filter: func(e *zoom.Edge, depth int) bool {
+			got = append(got, e.ID)
+			return true
+		},
+	}
+
+	if err := BFS(nodes["f"], opts, func(n *zoom.Node, via *zoom.Edge, depth int) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("edges followed in order %v, expected %v", got, ids)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	nodes := graph(t)
+
+	path, err := ShortestPath(nodes["a"], nodes["f"], "knows", "works")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hops []*zoom.Node
+	for _, e := range path {
+		hops = append(hops, e.To)
+	}
+
+	// a -> d -> e -> f and a -> b -> c -> e -> f: the first one is shorter
+	if got := names(t, hops); !reflect.DeepEqual(got, []string{"d", "e", "f"}) {
+		t.Errorf("ShortestPath a -> f = %v, expected %v", got, []string{"d", "e", "f"})
+	}
+
+	if path[0].From.ID() != nodes["a"].ID() || path[0].Category != "works" {
+		t.Errorf("first hop should be a -works-> d")
+	}
+
+	if _, err := ShortestPath(nodes["f"], nodes["a"], "knows"); err != ErrNoPath {
+		t.Errorf("ShortestPath f -> a returned %v, expected ErrNoPath", err)
+	}
+
+	path, err = ShortestPath(nodes["a"], nodes["a"], "knows")
+	if err != nil || len(path) != 0 {
+		t.Errorf("ShortestPath a -> a = %v, %v, expected empty path", path, err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	persons := memstore.New("persons")
+	cities := memstore.New("cities")
+
+	donald := zoom.NewNode(persons, "")
+	duckburg := zoom.NewNode(cities, "")
+	daisy := zoom.NewNode(persons, "")
+
+	for _, n := range []*zoom.Node{donald, duckburg, daisy} {
+		n.SetString("name", "x")
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := donald.NewEdge("near", duckburg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := duckburg.NewEdge("near", daisy, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ShortestPath(donald, daisy, "near"); err != ErrNoPath {
+		t.Errorf("ShortestPath without resolver returned %v, expected ErrNoPath", err)
+	}
+
+	path, err := ShortestPathWith(donald, daisy, Options{Categories: []string{"near"}, Resolver: zoom.NewResolver(persons, cities)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(path) != 2 || path[0].To.Transaction != cities {
+		t.Errorf("path via resolver = %v, expected 2 hops via duckburg", path)
+	}
+}
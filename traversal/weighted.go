@@ -0,0 +1,97 @@
+package traversal
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/metakeule/zoom"
+)
+
+type candidate struct {
+	node  *zoom.Node
+	via   *zoom.Edge
+	prev  *candidate // the candidate the edge via starts at
+	cost  float64
+	depth int
+}
+
+// settled holds the smallest depth each node has been settled with. Candidates are settled in the
+// order of their cost, so a settled node dominates all later candidates of the node with the same
+// or a larger depth. With MaxDepth, a node may be reached cheaper with more edges, so a node is
+// visited again, if it is reached with fewer edges. Without MaxDepth, a node is visited once.
+type settled map[string]int
+
+func (s settled) dominates(c candidate, opts Options) bool {
+	depth, has := s[key(c.node)]
+	return has && (opts.MaxDepth <= 0 || depth <= c.depth)
+}
+
+// candidates is a min heap of candidates, ordered by cost
+type candidates []candidate
+
+func (c candidates) Len() int            { return len(c) }
+func (c candidates) Less(i, j int) bool  { return c[i].cost < c[j].cost }
+func (c candidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *candidates) Push(x interface{}) { *c = append(*c, x.(candidate)) }
+func (c *candidates) Pop() interface{} {
+	old := *c
+	last := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return last
+}
+
+// ShortestWeightedPath returns the edges of the path with the smallest sum of edge weights
+// from one node to another, following the edges of the given categories, and the sum of the weights.
+// Edges without weight have a weight of 0, negative weights are an error.
+// If from and to are the same node, the path is empty. If there is no path, ErrNoPath is returned.
+func ShortestWeightedPath(from, to *zoom.Node, categories ...string) ([]*zoom.Edge, float64, error) {
+	return ShortestWeightedPathWith(from, to, Options{Categories: categories})
+}
+
+// ShortestWeightedPathWith is like ShortestWeightedPath, but respects MaxDepth and Filter of the given options.
+// MaxDepth limits the number of edges of the path. A node is then expanded again, whenever it is reached
+// with fewer edges than before, so up to MaxDepth times in the worst case.
+func ShortestWeightedPathWith(from, to *zoom.Node, opts Options) ([]*zoom.Edge, float64, error) {
+	target := key(to)
+	done := settled{}
+
+	queue := &candidates{{node: from}}
+
+	for queue.Len() > 0 {
+		c := heap.Pop(queue).(candidate)
+		if done.dominates(c, opts) {
+			continue
+		}
+		done[key(c.node)] = c.depth
+
+		if key(c.node) == target {
+			path := []*zoom.Edge{}
+			for p := &c; p.via != nil; p = p.prev {
+				path = append([]*zoom.Edge{p.via}, path...)
+			}
+			return path, c.cost, nil
+		}
+
+		if opts.tooDeep(c.depth + 1) {
+			continue
+		}
+
+		edges, err := opts.edges(c.node, c.depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, e := range edges {
+			if e.Weight < 0 {
+				return nil, 0, fmt.Errorf("edge %s from %s to %s has negative weight %v", e.Category, e.From.ID(), e.To.ID(), e.Weight)
+			}
+			next := candidate{node: e.To, via: e, prev: &c, cost: c.cost + e.Weight, depth: c.depth + 1}
+			if done.dominates(next, opts) {
+				continue
+			}
+			heap.Push(queue, next)
+		}
+	}
+
+	return nil, 0, ErrNoPath
+}
@@ -0,0 +1,182 @@
+package traversal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/metakeule/zoom"
+	"github.com/metakeule/zoom/memstore"
+)
+
+func TestShortestWeightedPath(t *testing.T) {
+	store := memstore.New("shard1")
+	nodes := map[string]*zoom.Node{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		n := zoom.NewNode(store, "")
+		n.SetString("name", name)
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = n
+	}
+
+	// a -> d is direct but expensive, a -> b -> c -> d is cheaper
+	edges := []struct {
+		from, to string
+		weight   float64
+	}{
+		{"a", "d", 10},
+		{"a", "b", 1},
+		{"b", "c", 2.5},
+		{"c", "d", 3},
+	}
+
+	for _, e := range edges {
+		if err := nodes[e.from].NewWeightedEdge("road", nodes[e.to], e.weight, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, cost, err := ShortestWeightedPath(nodes["a"], nodes["d"], "road")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != 6.5 {
+		t.Errorf("cost = %v, expected 6.5", cost)
+	}
+
+	var hops []*zoom.Node
+	for _, e := range path {
+		hops = append(hops, e.To)
+	}
+
+	if got := names(t, hops); !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Errorf("ShortestWeightedPath a -> d = %v, expected %v", got, []string{"b", "c", "d"})
+	}
+
+	// limited to one hop, only the direct edge is possible
+	path, cost, err = ShortestWeightedPathWith(nodes["a"], nodes["d"], Options{Categories: []string{"road"}, MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != 10 || len(path) != 1 {
+		t.Errorf("ShortestWeightedPathWith MaxDepth 1: cost %v, %d hops, expected 10 and 1 hop", cost, len(path))
+	}
+
+	if _, _, err := ShortestWeightedPath(nodes["d"], nodes["a"], "road"); err != ErrNoPath {
+		t.Errorf("ShortestWeightedPath d -> a returned %v, expected ErrNoPath", err)
+	}
+
+	if err := nodes["d"].NewWeightedEdge("road", nodes["a"], -1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ShortestWeightedPath(nodes["d"], nodes["b"], "road"); err == nil {
+		t.Errorf("expected error for negative weight")
+	}
+}
+
+func TestShortestWeightedPathMaxDepth(t *testing.T) {
+	store := memstore.New("shard1")
+	nodes := map[string]*zoom.Node{}
+	for _, name := range []string{"a", "b", "c", "t"} {
+		n := zoom.NewNode(store, "")
+		n.SetString("name", name)
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = n
+	}
+
+	// b is reached cheaper via c, but then t is too deep
+	edges := []struct {
+		from, to string
+		weight   float64
+	}{
+		{"a", "b", 10},
+		{"a", "c", 1},
+		{"c", "b", 1},
+		{"b", "t", 1},
+	}
+
+	for _, e := range edges {
+		if err := nodes[e.from].NewWeightedEdge("road", nodes[e.to], e.weight, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, cost, err := ShortestWeightedPathWith(nodes["a"], nodes["t"], Options{Categories: []string{"road"}, MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != 11 {
+		t.Errorf("cost = %v, expected 11", cost)
+	}
+
+	var hops []*zoom.Node
+	for _, e := range path {
+		hops = append(hops, e.To)
+	}
+
+	if got := names(t, hops); !reflect.DeepEqual(got, []string{"b", "t"}) {
+		t.Errorf("ShortestWeightedPathWith a -> t = %v, expected %v", got, []string{"b", "t"})
+	}
+}
+
+func TestShortestWeightedPathMaxDepthExpansions(t *testing.T) {
+	store := memstore.New("shard1")
+	nodes := map[string]*zoom.Node{}
+	for _, name := range []string{"a", "b", "c", "t"} {
+		n := zoom.NewNode(store, "")
+		n.SetString("name", name)
+		if err := n.Save(); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = n
+	}
+
+	// b is reached via c with more edges and a higher cost, so it must not be expanded again
+	edges := []struct {
+		from, to string
+		weight   float64
+	}{
+		{"a", "b", 1},
+		{"a", "c", 1},
+		{"c", "b", 1},
+		{"b", "t", 100},
+	}
+
+	for _, e := range edges {
+		if err := nodes[e.from].NewWeightedEdge("road", nodes[e.to], e.weight, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var expansions int
+	opts := Options{
+		Categories: []string{"road"},
+		MaxDepth:   3,
+		Filter: func(e *zoom.Edge, depth int) bool {
+			if e.From.ID() == nodes["b"].ID() {
+				expansions++
+			}
+			return true
+		},
+	}
+
+	_, cost, err := ShortestWeightedPathWith(nodes["a"], nodes["t"], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != 101 {
+		t.Errorf("cost = %v, expected 101", cost)
+	}
+
+	if expansions != 1 {
+		t.Errorf("b has been expanded %d times, expected once", expansions)
+	}
+}
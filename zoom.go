@@ -20,7 +20,6 @@ func (n *Name) Get(data map[string]interface{}) bool {
 }
 
 // TODO make shortcuts for common things like a Nameable struct, sticky things, children with parents, relations with properties
-// TODO calculate distances from a node to another via relations that have the requested key
 // TODO make shortcuts to add a new node. remove a node and update a node (with validation and store)
 // TODO make indexed collections to find nodes based on certain criterions, make rules to insert, update, remove
 // entries if they are indexed
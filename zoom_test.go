@@ -1,42 +1,26 @@
 package zoom_test
 
 import (
+	"io"
 	"io/ioutil"
-	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/metakeule/zoom"
-	"github.com/metakeule/zoom/gitstore"
+	"github.com/metakeule/zoom/memstore"
 )
 
-var base = "BASE"
-
 func TestStore(t *testing.T) {
-	dir, err := ioutil.TempDir(os.TempDir(), "zoom_")
-
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// fmt.Printf("temp dir: %s", dir)
-
-	defer os.RemoveAll(dir)
-
-	store, err := gitstore.Open(dir)
-	// store.Debug = true
-
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// store.Git.Debug = true
+	store := memstore.New("shard1")
 
-	actions := []func(zoom.Store) error{}
+	var nadja, benny *zoom.Node
 
-	nadja := zoom.NewNode("shard1")
-	benny := zoom.NewNode("shard1")
+	err := store.Transaction(zoom.CommitMessage{Command: "add persons and relations"}, func(tr zoom.Transaction) error {
+		nadja = zoom.NewNode(tr, "")
+		benny = zoom.NewNode(tr, "")
 
-	fn := func() []func(zoom.Store) error {
 		nadja.SetFloat("Age", 44)
 		nadja.SetString("FirstName", "Nadja")
 		nadja.SetString("LastName", "Poetschki")
@@ -45,33 +29,30 @@ func TestStore(t *testing.T) {
 		benny.SetString("FirstName", "Benny")
 		benny.SetString("LastName", "Arns")
 
-		// nadja.Update("friend-of", benny)
-
-		return []func(zoom.Store) error{nadja.Save, benny.Save}
-	}
-
-	actions = append(actions, fn()...)
-
-	_, err = store.Transaction("add persons and relations", actions...)
+		if err := nadja.Save(); err != nil {
+			return err
+		}
+		return benny.Save()
+	})
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// fmt.Println(nadja.ID())
+	var nadja2, benny2 *zoom.Node
+	err = store.Transaction(zoom.CommitMessage{Command: "get"}, func(tr zoom.Transaction) error {
+		nadja2 = zoom.NewNode(tr, nadja.ID())
+		benny2 = zoom.NewNode(tr, benny.ID())
 
-	var nadja2 = zoom.NewNode(nadja.ID())
-	var benny2 = zoom.NewNode(benny.ID())
-	_, err = store.Transaction("get", func(st zoom.Store) error {
-		if err := nadja2.LoadProperties(st, []string{"Age", "FirstName", "LastName"}); err != nil {
+		if err := nadja2.LoadProperties([]string{"Age", "FirstName", "LastName"}); err != nil {
 			return err
 		}
 
-		if err := benny2.LoadProperties(st, []string{"Age", "FirstName", "LastName"}); err != nil {
+		if err := benny2.LoadProperties([]string{"Age", "FirstName", "LastName"}); err != nil {
 			return err
 		}
 
-		return nil
+		return zoom.ErrNoCommit
 	})
 
 	if err != nil {
@@ -103,3 +84,117 @@ func TestStore(t *testing.T) {
 	}
 
 }
+
+func TestBlob(t *testing.T) {
+	store := memstore.New("shard1")
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "add avatar"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		n.SetString("FirstName", "Nadja")
+		n.SetBlob("image/png/avatar", strings.NewReader("PNG"))
+		id = n.ID()
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var avatar string
+
+	n := zoom.NewNode(store, id)
+	err = n.LoadBlobs([]string{"image/png/avatar"}, func(name string, rd io.Reader) error {
+		b, err := ioutil.ReadAll(rd)
+		avatar = string(b)
+		return err
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if avatar != "PNG" {
+		t.Errorf("wrong avatar expected: %#v, got %#v", "PNG", avatar)
+	}
+}
+
+func TestMultiValued(t *testing.T) {
+	store := memstore.New("shard1")
+	born := time.Date(1971, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	var id string
+
+	err := store.Transaction(zoom.CommitMessage{Command: "save"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, "")
+		id = n.ID()
+		if err := n.SetStrings("Tags", "duck", "famous"); err != nil {
+			return err
+		}
+		if err := n.SetStrings("Empty"); err != nil {
+			return err
+		}
+		n.SetInts("Ints", 1, 9007199254740993)
+		n.SetFloats("Floats", 1.5)
+		n.SetBools("Bools", true, false)
+		n.SetTimes("Times", born)
+		n.SetString("Removed", "soon")
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Transaction(zoom.CommitMessage{Command: "remove"}, func(tr zoom.Transaction) error {
+		n := zoom.NewNode(tr, id)
+		n.RemoveProperty("Removed")
+		return n.Save()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := zoom.NewNode(store, id)
+	if err := n.LoadProperties([]string{"Tags", "Empty", "Ints", "Floats", "Bools", "Times", "Removed", "Missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := n.GetStrings("Tags"); !reflect.DeepEqual(got, []string{"duck", "famous"}) {
+		t.Errorf("Tags = %#v", got)
+	}
+
+	if got := n.GetStrings("Empty"); got == nil || len(got) != 0 {
+		t.Errorf("Empty = %#v, expected empty slice", got)
+	}
+
+	if got := n.GetInts("Ints"); !reflect.DeepEqual(got, []int64{1, 9007199254740993}) {
+		t.Errorf("Ints = %#v", got)
+	}
+
+	if got := n.GetFloats("Floats"); !reflect.DeepEqual(got, []float64{1.5}) {
+		t.Errorf("Floats = %#v", got)
+	}
+
+	if got := n.GetBools("Bools"); !reflect.DeepEqual(got, []bool{true, false}) {
+		t.Errorf("Bools = %#v", got)
+	}
+
+	if got := n.GetTimes("Times"); len(got) != 1 || !got[0].Equal(born) {
+		t.Errorf("Times = %#v", got)
+	}
+
+	if _, has := n.Properties()["Removed"]; has {
+		t.Errorf("Removed property still exists")
+	}
+
+	if got := n.GetStrings("Missing"); got != nil {
+		t.Errorf("Missing = %#v, expected nil", got)
+	}
+
+	if err := n.SetStrings("Tags", "ok", strings.Repeat("x", 256)); err == nil {
+		t.Errorf("expected error for string larger than 255 bytes")
+	}
+}